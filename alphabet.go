@@ -0,0 +1,209 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+)
+
+// Statuses of a probed character. All but probeOK and probeUntestedClass fail the
+// command, a class can match more than its generators need to cover.
+const (
+	probeOK              = "ok"
+	probeUntested        = "accepted, never generated"
+	probeUntestedIllegal = "rejected, never generated"
+	probeUntestedClass   = "never generated for a class it matches"
+	probeIllegalAllowed  = "listed illegal, but accepted"
+	probeGeneratedDenied = "generated, but rejected"
+)
+
+// probeRanges are the named ranges for -range
+var probeRanges = map[string][2]rune{
+	"ascii":  {0, 0x7f},
+	"latin1": {0, 0xff},
+	"bmp":    {0, 0xffff},
+	"all":    {0, unicode.MaxRune},
+}
+
+// parseProbeRange reads a named range or one like U+0000-U+00FF
+func parseProbeRange(spec string) (rune, rune, error) {
+	if named, ok := probeRanges[spec]; ok {
+		return named[0], named[1], nil
+	}
+	parse := func(s string) (rune, error) {
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(s), "U+"), 16, 32)
+		if err != nil || n > unicode.MaxRune {
+			return 0, fmt.Errorf("%q isn't a code point like U+00FF", s)
+		}
+		return rune(n), nil
+	}
+	first, last, _ := strings.Cut(spec, "-")
+	if last == "" {
+		last = first
+	}
+	lo, err := parse(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	hi, err := parse(last)
+	if err != nil {
+		return 0, 0, err
+	}
+	if hi < lo {
+		return 0, 0, fmt.Errorf("range %s ends before it starts", spec)
+	}
+	return lo, hi, nil
+}
+
+// probedChar is what the policy and the generators make of one character
+type probedChar struct {
+	allowed   bool
+	classes   string
+	generated string
+	status    string
+}
+
+// probeChar runs a single character through the alphabet and every class regex,
+// and looks it up in the characters the generators use
+func probeChar(r rune, generatedAs map[rune][]string, illegal map[rune]bool) probedChar {
+	s := string(r)
+	probed := probedChar{allowed: activePolicy.Allowed(r), status: probeOK}
+
+	var matched []string
+	missing := false
+	for _, class := range activePolicy.Classes {
+		if !class.Regexp().MatchString(s) {
+			continue
+		}
+		matched = append(matched, class.Name)
+		if !strings.ContainsRune(class.Chars, r) {
+			missing = true
+		}
+	}
+	probed.classes = strings.Join(matched, ", ")
+
+	generated := generatedAs[r]
+	if illegal[r] {
+		generated = append(generated, "illegal")
+	}
+	probed.generated = strings.Join(generated, ", ")
+
+	switch {
+	case probed.allowed && illegal[r]:
+		probed.status = probeIllegalAllowed
+	case !probed.allowed && len(generatedAs[r]) > 0:
+		probed.status = probeGeneratedDenied
+	case probed.allowed && len(generatedAs[r]) == 0:
+		probed.status = probeUntested
+	case probed.allowed && missing:
+		probed.status = probeUntestedClass
+	case !probed.allowed && !illegal[r] && len(generatedAs[r]) == 0:
+		// No generator tries it, so nothing checks the validator rejects it
+		probed.status = probeUntestedIllegal
+	}
+	return probed
+}
+
+func describeProbeRange(lo rune, hi rune) (string, string) {
+	if lo == hi {
+		return fmt.Sprintf("U+%04X", lo), strconv.QuoteRune(lo)
+	}
+	return fmt.Sprintf("U+%04X-U+%04X", lo, hi), strconv.QuoteRune(lo) + ".." + strconv.QuoteRune(hi)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+func runAlphabet(args []string) {
+	fs := flag.NewFlagSet("alphabet", flag.ExitOnError)
+	rangeSpec := fs.String("range", "ascii", "Code points to probe: ascii, latin1, bmp, all or a range like U+0000-U+00FF")
+	flaggedOnly := fs.Bool("flagged", false, "Only list the characters that are flagged")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "alphabet [flags]", "Probes every code point of a range through the alphabet and class regexes, and compares what they accept with the characters the generators use. Exits with 1 when a character is accepted or rejected but never generated, or the two contradict each other.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	lo, hi, err := parseProbeRange(*rangeSpec)
+	if err != nil {
+		usageError(fs, "%s", err)
+	}
+
+	generatedAs := make(map[rune][]string)
+	for _, class := range activePolicy.Classes {
+		for _, r := range class.Chars {
+			generatedAs[r] = append(generatedAs[r], class.Name)
+		}
+	}
+	illegal := make(map[rune]bool)
+	for _, r := range activePolicy.IllegalChars {
+		illegal[r] = true
+	}
+
+	fmt.Printf("Probing U+%04X-U+%04X against policy %s\n\n", lo, hi, activePolicy.Name)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Code points\tCharacters\tAllowed\tClasses\tGenerated as\tStatus\t")
+
+	counts := make(map[string]int)
+	probed := 0
+	var runStart rune
+	var run probedChar
+	inRun := false
+	flush := func(end rune) {
+		if !inRun || (*flaggedOnly && run.status == probeOK) {
+			return
+		}
+		codePoints, chars := describeProbeRange(runStart, end)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t\n", codePoints, chars, yesNo(run.allowed), orDash(run.classes), orDash(run.generated), run.status)
+	}
+	for r := lo; r <= hi; r++ {
+		// Surrogates aren't characters on their own, Go turns them into U+FFFD
+		if r >= 0xd800 && r <= 0xdfff {
+			flush(r - 1)
+			inRun = false
+			continue
+		}
+		current := probeChar(r, generatedAs, illegal)
+		probed += 1
+		counts[current.status] += 1
+		if inRun && current == run {
+			continue
+		}
+		flush(r - 1)
+		runStart, run, inRun = r, current, true
+	}
+	flush(hi)
+	w.Flush()
+
+	fmt.Printf("\nProbed %d code points\n", probed)
+	flagged := 0
+	for _, status := range []string{probeOK, probeUntested, probeUntestedIllegal, probeUntestedClass, probeIllegalAllowed, probeGeneratedDenied} {
+		if counts[status] > 0 {
+			fmt.Printf("  %-40s %d\n", status, counts[status])
+		}
+		if status != probeOK && status != probeUntestedClass {
+			flagged += counts[status]
+		}
+	}
+	if flagged > 0 {
+		os.Exit(exitThresholdExceeded)
+	}
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+const baselineFile = "baseline.csv"
+
+var baselineHeader = []string{"kind", "failure", "value"}
+
+// baselineEntry accepts a mismatch either by exact password or by failure signature.
+// The failure type is part of the entry so an accepted false reject can never hide
+// a false accept of the same shape.
+type baselineEntry struct {
+	kind    string
+	failure string
+	value   string
+}
+
+type knownFailures struct {
+	entries map[baselineEntry]bool // Whether the entry matched a failure of this run
+}
+
+func failureType(result testResults) string {
+	if result.actualResult {
+		return "false-accept"
+	}
+	return "false-reject"
+}
+
+func loadBaseline(path string) (*knownFailures, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = len(baselineHeader)
+	if _, err := reader.Read(); err != nil {
+		return nil, fmt.Errorf("%s: error while reading header: %w", path, err)
+	}
+
+	known := &knownFailures{entries: make(map[baselineEntry]bool)}
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return known, nil
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		entry := baselineEntry{kind: line[0], failure: line[1], value: line[2]}
+		if entry.kind != "password" && entry.kind != "signature" {
+			return nil, fmt.Errorf("%s: unknown baseline kind %q, expected password or signature", path, entry.kind)
+		}
+		if entry.failure != "false-accept" && entry.failure != "false-reject" {
+			return nil, fmt.Errorf("%s: unknown failure type %q, expected false-accept or false-reject", path, entry.failure)
+		}
+		known.entries[entry] = false
+	}
+}
+
+// known reports whether a failed result is accepted by the baseline
+func (k *knownFailures) known(result testResults) bool {
+	failure := failureType(result)
+	for _, entry := range []baselineEntry{
+		{kind: "password", failure: failure, value: result.testedPassword},
+		{kind: "signature", failure: failure, value: failureSignature(result.testedPassword)},
+	} {
+		if _, ok := k.entries[entry]; ok {
+			k.entries[entry] = true
+			return true
+		}
+	}
+	return false
+}
+
+// fixed returns the baseline entries that no failure matched
+func (k *knownFailures) fixed() []baselineEntry {
+	var fixed []baselineEntry
+	for entry, matched := range k.entries {
+		if !matched {
+			fixed = append(fixed, entry)
+		}
+	}
+	sortBaseline(fixed)
+	return fixed
+}
+
+func sortBaseline(entries []baselineEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].kind != entries[j].kind {
+			return entries[i].kind < entries[j].kind
+		}
+		if entries[i].failure != entries[j].failure {
+			return entries[i].failure < entries[j].failure
+		}
+		return entries[i].value < entries[j].value
+	})
+}
+
+func writeBaseline(path string, entries []baselineEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	err = writer.Write(baselineHeader)
+	for _, entry := range entries {
+		if err != nil {
+			break
+		}
+		err = writer.Write([]string{entry.kind, entry.failure, entry.value})
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func runBaseline(args []string) {
+	fs := flag.NewFlagSet("baseline", flag.ExitOnError)
+	output := fs.String("o", baselineFile, "File to write the baseline to")
+	bySignature := fs.Bool("by-signature", false, "Accept failures by their cluster signature instead of by password")
+	fs.Usage = func() {
+		printUsage(fs, "baseline [flags] [RESULTS.csv]", fmt.Sprintf("Regenerates the baseline of accepted failures from a results file (%s by default).", resultsFile))
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(exitUsageError)
+	}
+	path := resultsFile
+	if fs.NArg() == 1 {
+		path = fs.Arg(0)
+	}
+
+	entries := make(map[baselineEntry]bool)
+	err := readResults(path, func(result testResults) {
+		if result.expectedResult == result.actualResult {
+			return
+		}
+		entry := baselineEntry{kind: "password", failure: failureType(result), value: result.testedPassword}
+		if *bySignature {
+			entry.kind = "signature"
+			entry.value = failureSignature(result.testedPassword)
+		}
+		entries[entry] = true
+	})
+	if err != nil {
+		fatalIO("Error while reading results: ", err)
+	}
+
+	sorted := make([]baselineEntry, 0, len(entries))
+	for entry := range entries {
+		sorted = append(sorted, entry)
+	}
+	sortBaseline(sorted)
+	err = writeBaseline(*output, sorted)
+	if err != nil {
+		fatalIOf("Error while writing baseline to file %s\n%s\n", *output, err)
+	}
+	fmt.Printf("Wrote %d accepted failures to %s\n", len(sorted), *output)
+}
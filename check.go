@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+type checkResult struct {
+	Password string           `json:"password"`
+	Accepted bool             `json:"accepted"`
+	Failures []policy.Failure `json:"failures"`
+}
+
+// scanNUL splits input on NUL bytes, for passwords that contain newlines
+func scanNUL(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+func runCheck(args []string) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	input := fs.String("file", "", "Read passwords from this file instead of stdin")
+	nulDelimited := fs.Bool("0", false, "Passwords are separated by NUL characters instead of newlines")
+	jsonOutput := fs.Bool("json", false, "Print one JSON object per password instead of text")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "check [flags] [PASSWORD...]", "Checks the given passwords, or one password per line from stdin or -file, against the policy and lists the rules each rejected password breaks. Exits with 1 when a password is rejected.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 && *input != "" {
+		usageError(fs, "passwords can't be given as arguments together with -file")
+	}
+	if fs.NArg() > 0 && *nulDelimited {
+		usageError(fs, "-0 only applies to passwords read from stdin or -file")
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetEscapeHTML(false)
+	rejected := false
+	check := func(passwd string) {
+		verdict := activePolicy.Validate(passwd)
+		result := checkResult{Password: passwd, Accepted: verdict.Accepted, Failures: verdict.Failures}
+		if !result.Accepted {
+			rejected = true
+		}
+
+		if *jsonOutput {
+			if err := encoder.Encode(result); err != nil {
+				fatalIO("Error while writing results: ", err)
+			}
+			return
+		}
+		if result.Accepted {
+			fmt.Printf("accept %q\n", passwd)
+			return
+		}
+		messages := make([]string, len(result.Failures))
+		for i, failure := range result.Failures {
+			messages[i] = failure.Message
+		}
+		fmt.Printf("reject %q: %s\n", passwd, strings.Join(messages, "; "))
+	}
+
+	if fs.NArg() > 0 {
+		for _, passwd := range fs.Args() {
+			check(passwd)
+		}
+	} else {
+		var reader io.Reader = os.Stdin
+		if *input != "" {
+			file, err := os.Open(*input)
+			if err != nil {
+				fatalIO("Error while opening passwords: ", err)
+			}
+			defer file.Close()
+			reader = file
+		}
+
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+		if *nulDelimited {
+			scanner.Split(scanNUL)
+		}
+		for scanner.Scan() {
+			check(scanner.Text())
+		}
+		if err := scanner.Err(); err != nil {
+			fatalIO("Error while reading passwords: ", err)
+		}
+	}
+
+	if rejected {
+		os.Exit(exitThresholdExceeded)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const clusterExamples = 2
+
+type failureCluster struct {
+	signature string
+	count     int
+	examples  []testResults
+}
+
+func lengthBucket(length int) string {
+	if length == 0 {
+		return "0"
+	} else if length < activePolicy.MinLength {
+		return fmt.Sprintf("1-%d", activePolicy.MinLength-1)
+	}
+
+	low := max(activePolicy.MinLength, 1)
+	for low*2 <= 64 {
+		if length < low*2 {
+			return fmt.Sprintf("%d-%d", low, low*2-1)
+		}
+		low *= 2
+	}
+	return fmt.Sprintf("%d+", low)
+}
+
+// failureSignature describes the shape of a password rather than its content, so
+// failures caused by the same quirk end up in the same cluster
+func failureSignature(passwd string) string {
+	// One letter per class of the policy, like ULNS
+	classes := []byte(strings.Repeat("-", len(activePolicy.Classes)))
+	for i, class := range activePolicy.Classes {
+		if class.Regexp().MatchString(passwd) {
+			classes[i] = strings.ToUpper(class.Name)[0]
+		}
+	}
+
+	// Find which characters aren't part of the alphabet, and where the first one is
+	runes := []rune(passwd)
+	illegal := make(map[rune]bool)
+	firstIllegal := -1
+	for i, r := range runes {
+		if !activePolicy.Allowed(r) {
+			illegal[r] = true
+			if firstIllegal == -1 {
+				firstIllegal = i
+			}
+		}
+	}
+	illegalChars := make([]string, 0, len(illegal))
+	for r := range illegal {
+		illegalChars = append(illegalChars, string(r))
+	}
+	sort.Strings(illegalChars)
+
+	position := "none"
+	if firstIllegal == 0 {
+		position = "start"
+	} else if firstIllegal == len(runes)-1 {
+		position = "end"
+	} else if firstIllegal > 0 {
+		position = "middle"
+	}
+
+	illegalDescription := "none"
+	if len(illegalChars) > 0 {
+		illegalDescription = fmt.Sprintf("%q", strings.Join(illegalChars, ""))
+	}
+
+	return fmt.Sprintf("length=%s classes=%s illegal=%s first-illegal=%s", lengthBucket(len(runes)), classes, illegalDescription, position)
+}
+
+// clusterFailures groups failures by signature, largest cluster first
+func clusterFailures(failures []testResults) []*failureCluster {
+	clusters := make(map[string]*failureCluster)
+	for _, result := range failures {
+		signature := failureSignature(result.testedPassword)
+		cluster := clusters[signature]
+		if cluster == nil {
+			cluster = &failureCluster{signature: signature}
+			clusters[signature] = cluster
+		}
+		cluster.count += 1
+		if len(cluster.examples) < clusterExamples {
+			cluster.examples = append(cluster.examples, result)
+		}
+	}
+
+	sorted := make([]*failureCluster, 0, len(clusters))
+	for _, cluster := range clusters {
+		sorted = append(sorted, cluster)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].signature < sorted[j].signature
+	})
+	return sorted
+}
+
+func printClusters(failures []testResults) {
+	for _, cluster := range clusterFailures(failures) {
+		examples := make([]string, len(cluster.examples))
+		for i, example := range cluster.examples {
+			examples[i] = fmt.Sprintf("%q (%s)", example.testedPassword, describeResult(example))
+		}
+		fmt.Printf("%8d  %s\n", cluster.count, cluster.signature)
+		fmt.Printf("          e.g. %s\n", strings.Join(examples, ", "))
+	}
+}
@@ -0,0 +1,336 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+const programName = "credstester"
+
+type command struct {
+	name    string
+	summary string
+	run     func(args []string)
+}
+
+var commands = []command{
+	{name: "run", summary: "Generate passwords for each category and record their verdicts in " + resultsFile, run: runRunCommand},
+	{name: "eval", summary: "Evaluate " + resultsFile + " and golden files against their expected verdicts", run: runEvalCommand},
+	{name: "check", summary: "Check passwords against the policy", run: runCheck},
+	{name: "explain", summary: "Explain how the policy treats a single password", run: runExplain},
+	{name: "generate", summary: "Generate passwords that comply with the policy", run: runGenerate},
+	{name: "diff", summary: "Compare the verdicts of two results files", run: runDiff},
+	{name: "baseline", summary: "Regenerate the baseline of accepted failures", run: runBaseline},
+	{name: "serve", summary: "Serve validation and generation over HTTP", run: runServe},
+	{name: "js-compat", summary: "Compare the policy patterns in Go and JavaScript", run: runJSCompat},
+	{name: "export", summary: "Export the policy as HTML, JSON Schema, OpenAPI or Python", run: runExport},
+	{name: "lint", summary: "Check the policy for contradicting and unsatisfiable rules", run: runLint},
+	{name: "alphabet", summary: "Probe which characters the policy accepts and the generators use", run: runAlphabet},
+	{name: "compare", summary: "Compare the policy with presets for NIST, OWASP ASVS and PCI DSS", run: runCompare},
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s COMMAND [flags]\n\nCommands:\n", programName)
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun \"%s COMMAND -h\" for the flags of a command. Running without a command accepts the original flags, see \"%s -h\".\n", programName, programName)
+}
+
+func runCommand(name string, args []string) {
+	if name == "help" {
+		if len(args) == 0 {
+			printCommands(os.Stdout)
+			return
+		}
+		name, args = args[0], []string{"-h"}
+	}
+
+	for _, c := range commands {
+		if c.name == name {
+			loadPolicy()
+			c.run(args)
+			return
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+	printCommands(os.Stderr)
+	os.Exit(exitUsageError)
+}
+
+// setFlags returns the names of the flags that were given on the command line
+func setFlags(fs *flag.FlagSet) map[string]bool {
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+	return set
+}
+
+func usageError(fs *flag.FlagSet, format string, v ...any) {
+	fmt.Fprintf(fs.Output(), "Error: "+format+"\n\n", v...)
+	fs.Usage()
+	os.Exit(exitUsageError)
+}
+
+func addRunFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&showProgress, "show-progress", false, "Print progress to stdout")
+	fs.BoolVar(&showDashboard, "dashboard", false, "Show a full screen dashboard, falls back to -show-progress when stdout isn't a terminal")
+	fs.BoolVar(&exitOnFail, "exit-on-fail", false, "Exit immediately on fail")
+	fs.BoolVar(&failuresOnly, "failures-only", false, "Only write mismatching passwords to "+resultsFile+", totals are kept in "+countsFile)
+}
+
+func addEvalFlags(fs *flag.FlagSet) {
+	fs.StringVar(&goldenFile, "golden", "", "Evaluate a CSV or JSONL file of passwords labeled with their expected verdict")
+	fs.StringVar(&goldenFileFormat, "golden-format", "", "Format of the golden file (csv or jsonl), detected from the file extension by default")
+	fs.BoolVar(&listFailures, "list-failures", false, "List every failed password during evals instead of only the failure clusters")
+	fs.Float64Var(&maxFalseAcceptRate, "max-false-accept-rate", 0, "Highest allowed percentage of illegal passwords being accepted")
+	fs.Float64Var(&maxFalseRejectRate, "max-false-reject-rate", 0, "Highest allowed percentage of legal passwords being rejected")
+	fs.Var(categoryLimits, "category-threshold", "Per category thresholds as CATEGORY:false-accept=RATE,false-reject=RATE, may be repeated")
+}
+
+func addPolicyFlags(fs *flag.FlagSet) {
+	fs.StringVar(&keycloakPolicy, "keycloak", "", "Use a Keycloak password policy string, like \"length(8) and upperCase(1) and notUsername\"")
+	fs.StringVar(&pwqualityPath, "pwquality", "", "Use the policy of a pwquality.conf file")
+	fs.StringVar(&loginDefsPath, "login-defs", "", "Use the password length of a login.defs file")
+	fs.StringVar(&presetName, "preset", "", "Use a built-in policy modeled on a standard: "+strings.Join(policy.PresetNames(), ", "))
+	fs.StringVar(&policyUser.Username, "username", "", "Username for the rules that compare passwords with the account")
+	fs.StringVar(&policyUser.Email, "email", "", "Email address for the rules that compare passwords with the account")
+	fs.StringVar(&policyUser.DisplayName, "display-name", "", "Display name for the rules that compare passwords with the account")
+	fs.StringVar(&sampleUser, "profile", "", "Use the account of a sample user profile: "+strings.Join(sampleUsernames(), ", "))
+	fs.IntVar(&maxRepeat, "max-repeat", 0, "Also ban the same character more than this many times in a row, like aaaa")
+	fs.IntVar(&maxSequence, "max-sequence", 0, "Also ban sequences longer than this, like abcd or 4321")
+	fs.IntVar(&maxKeyboardWalk, "max-keyboard-walk", 0, "Also ban keyboard walks longer than this, like qwer")
+}
+
+func sampleUsernames() []string {
+	var names []string
+	for _, user := range policy.SampleUsers() {
+		names = append(names, user.Username)
+	}
+	return names
+}
+
+// parsePolicyFile reads a policy file with one of the policy importers, a file
+// that can't be read or parsed is an I/O error rather than a usage error
+func parsePolicyFile(path string, parse func(io.Reader) (policy.Policy, []string, error)) (policy.Policy, []string) {
+	file, err := os.Open(path)
+	if err != nil {
+		fatalIO(err)
+	}
+	defer file.Close()
+	definition, warnings, err := parse(file)
+	if err != nil {
+		fatalIOf("Error while reading policy %s: %s\n", path, err)
+	}
+	for i := range warnings {
+		warnings[i] = path + ": " + warnings[i]
+	}
+	return definition, warnings
+}
+
+// readPolicyFlags returns the definition the policy flags describe, before New
+// checks it, so lint can report what New would reject
+func readPolicyFlags(fs *flag.FlagSet) policy.Policy {
+	sources := 0
+	for _, source := range []string{keycloakPolicy, pwqualityPath, loginDefsPath, presetName} {
+		if source != "" {
+			sources += 1
+		}
+	}
+	if sources > 1 {
+		usageError(fs, "only one of -keycloak, -pwquality, -login-defs and -preset can be given")
+	}
+
+	definition := *policy.Default()
+	var warnings []string
+	var err error
+	switch {
+	case keycloakPolicy != "":
+		definition, warnings, err = policy.ParseKeycloakDefinition(keycloakPolicy)
+	case pwqualityPath != "":
+		definition, warnings = parsePolicyFile(pwqualityPath, policy.ParsePwqualityDefinition)
+	case loginDefsPath != "":
+		definition, warnings = parsePolicyFile(loginDefsPath, policy.ParseLoginDefsDefinition)
+	case presetName != "":
+		preset, ok := policy.LookupPreset(presetName)
+		if !ok {
+			usageError(fs, "unknown preset %q, expected one of %s", presetName, strings.Join(policy.PresetNames(), ", "))
+		}
+		definition = *preset.Policy
+	}
+	if err != nil {
+		usageError(fs, "%s", err)
+	}
+	for _, warning := range warnings {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", warning)
+	}
+
+	if maxRepeat < 0 || maxSequence < 0 || maxKeyboardWalk < 0 {
+		usageError(fs, "-max-repeat, -max-sequence and -max-keyboard-walk can't be negative")
+	}
+	if maxRepeat > 0 {
+		definition.MaxRepeat = maxRepeat
+	}
+	if maxSequence > 0 {
+		definition.MaxSequence = maxSequence
+	}
+	if maxKeyboardWalk > 0 {
+		definition.MaxKeyboardWalk = maxKeyboardWalk
+	}
+
+	if sampleUser != "" {
+		if policyUser != (policy.User{}) {
+			usageError(fs, "-profile can't be combined with -username, -email and -display-name")
+		}
+		var ok bool
+		policyUser, ok = policy.LookupSampleUser(sampleUser)
+		if !ok {
+			usageError(fs, "unknown profile %q, expected one of %s", sampleUser, strings.Join(sampleUsernames(), ", "))
+		}
+	}
+	return definition
+}
+
+// applyPolicyFlags replaces the default policy with the one the flags describe
+func applyPolicyFlags(fs *flag.FlagSet) {
+	p, err := policy.New(readPolicyFlags(fs))
+	if err != nil && pwqualityPath != "" {
+		fatalIOf("Error while reading policy %s: %s\n", pwqualityPath, err)
+	} else if err != nil && loginDefsPath != "" {
+		fatalIOf("Error while reading policy %s: %s\n", loginDefsPath, err)
+	} else if err != nil {
+		usageError(fs, "%s", err)
+	}
+	activePolicy = p
+	if policyUser != (policy.User{}) {
+		activePolicy = activePolicy.ForUser(policyUser)
+	}
+}
+
+func addBaselineFlag(fs *flag.FlagSet) {
+	fs.StringVar(&baselinePath, "baseline", "", "Baseline of accepted failures, only new failures fail the run")
+}
+
+func validateEvalFlags(fs *flag.FlagSet, set map[string]bool) {
+	if set["golden-format"] && goldenFile == "" {
+		usageError(fs, "-golden-format needs -golden")
+	}
+	if goldenFileFormat != "" && goldenFormat(goldenFile, goldenFileFormat) != "csv" && goldenFormat(goldenFile, goldenFileFormat) != "jsonl" {
+		usageError(fs, "unknown golden file format %q, expected csv or jsonl", goldenFileFormat)
+	}
+	if maxFalseAcceptRate < 0 || maxFalseRejectRate < 0 {
+		usageError(fs, "thresholds can't be negative")
+	}
+}
+
+func categoryNames() []string {
+	var names []string
+	for _, category := range activePolicy.Categories() {
+		names = append(names, category.Name)
+	}
+	return names
+}
+
+func runRunCommand(args []string) {
+	names := categoryNames()
+
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.IntVar(&testsToRun, "n", 100, "Number of passwords to generate for each category")
+	categoryList := fs.String("categories", "all", "Comma separated categories to run: all, "+strings.Join(names, ", ")+" for the default policy")
+	fs.Int64Var(&testSeed, "seed", 0, "Seed for the generated passwords, the seed of every run is printed so it can be repeated")
+	fs.StringVar(&targetPath, "wasm", "", "Validate with a WebAssembly module exporting memory, alloc(size) and validate(ptr, len) instead of the policy")
+	wasmMemory := fs.Int("wasm-max-memory", 64, "Most memory the -wasm module can use, in MiB")
+	wasmTimeout := fs.Duration("wasm-timeout", time.Second, "Longest a single call into the -wasm module can take")
+	verbose := fs.Bool("verbose", false, "Show verbose output")
+	addRunFlags(fs)
+	addBaselineFlag(fs)
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "run [flags]", "Generates passwords for each category, runs them through the policy and writes the verdicts to "+resultsFile+".")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+
+	set := setFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if testsToRun <= 0 {
+		usageError(fs, "-n must be a positive number")
+	}
+	if set["baseline"] && !exitOnFail {
+		usageError(fs, "-baseline has no effect without -exit-on-fail")
+	}
+	for _, name := range strings.Split(*categoryList, ",") {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			runAllTests = true
+			continue
+		}
+		found := false
+		for _, category := range categoryNames() {
+			if category == name {
+				categoriesToRun = append(categoriesToRun, name)
+				found = true
+			}
+		}
+		if !found {
+			usageError(fs, "unknown category %q", name)
+		}
+	}
+
+	if (set["wasm-max-memory"] || set["wasm-timeout"]) && targetPath == "" {
+		usageError(fs, "-wasm-max-memory and -wasm-timeout need -wasm")
+	}
+	if *wasmMemory <= 0 || *wasmMemory > 4096 || *wasmTimeout <= 0 {
+		usageError(fs, "-wasm-max-memory must be between 1 and 4096 MiB and -wasm-timeout positive")
+	}
+
+	doTests = true
+	if targetPath != "" {
+		var err error
+		targetValidator, err = loadWasmValidator(targetPath, *wasmMemory, *wasmTimeout)
+		if err != nil {
+			fatalIO("Error while loading WebAssembly validator: ", err)
+		}
+		defer targetValidator.Close()
+	}
+	if *verbose {
+		printSettings()
+	}
+	loadKnownBaseline()
+	runTests(time.Now())
+}
+
+func runEvalCommand(args []string) {
+	fs := flag.NewFlagSet("eval", flag.ExitOnError)
+	goldenOnly := fs.Bool("golden-only", false, "Only evaluate the golden file, not "+resultsFile)
+	addEvalFlags(fs)
+	addBaselineFlag(fs)
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "eval [flags]", "Reports how the verdicts in "+resultsFile+" and the optional golden file compare to their expected verdicts.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+
+	set := setFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *goldenOnly && goldenFile == "" {
+		usageError(fs, "-golden-only needs -golden")
+	}
+	validateEvalFlags(fs, set)
+
+	doEvals = !*goldenOnly
+	loadKnownBaseline()
+	runEvals(time.Now())
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+// compareProbes are passwords the standards disagree about that the generators
+// rarely produce: passphrases, long passwords and characters outside ASCII
+var compareProbes = []string{
+	"correct horse battery staple",
+	"Xk9#mQ2$",
+	"abcdefghijkl",
+	"kitchen-window-purple-42",
+	"Tr0ub4dor&3Tr0ub4dor&3Tr0ub4dor&3Tr0ub4dor&3Tr0ub4dor&3Tr0ub4dor",
+	"Ünicöde-Pässwörd-2024",
+	"password",
+	"Password1!",
+}
+
+// comparison collects the passwords one policy rejects and the other accepts,
+// grouped by the rules that rejected them
+type comparison struct {
+	groups map[string][]string
+	total  int
+}
+
+func (c *comparison) add(failures []policy.Failure, password string) {
+	var rules []string
+	for _, failure := range failures {
+		if len(rules) == 0 || rules[len(rules)-1] != failure.Rule {
+			rules = append(rules, failure.Rule)
+		}
+	}
+	key := strings.Join(rules, ", ")
+	c.groups[key] = append(c.groups[key], password)
+	c.total += 1
+}
+
+func (c *comparison) print(title string, verb string, examples int) {
+	fmt.Printf("  %s: %d\n", title, c.total)
+	keys := make([]string, 0, len(c.groups))
+	for key := range c.groups {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(c.groups[keys[i]]) != len(c.groups[keys[j]]) {
+			return len(c.groups[keys[i]]) > len(c.groups[keys[j]])
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys {
+		// The shortest passwords make the clearest examples
+		group := c.groups[key]
+		sort.Slice(group, func(i, j int) bool {
+			if len(group[i]) != len(group[j]) {
+				return len(group[i]) < len(group[j])
+			}
+			return group[i] < group[j]
+		})
+		quoted := make([]string, 0, examples)
+		for _, password := range group[:min(len(group), examples)] {
+			quoted = append(quoted, fmt.Sprintf("%q", password))
+		}
+		fmt.Printf("    %s %s (%d): %s\n", verb, key, len(group), strings.Join(quoted, ", "))
+	}
+}
+
+// comparePasswords generates passwords for every category of both policies, so
+// each side's edge cases are tried on the other
+func comparePasswords(policies []*policy.Policy, iterations int) ([]string, int64) {
+	seen := make(map[string]bool)
+	var passwords []string
+	add := func(password string) {
+		if !seen[password] {
+			seen[password] = true
+			passwords = append(passwords, password)
+		}
+	}
+	for _, probe := range compareProbes {
+		add(probe)
+	}
+	seed := testSeed
+	for _, p := range policies {
+		options := []policy.Option{policy.WithIterations(iterations)}
+		if seed != 0 {
+			options = append(options, policy.WithSeed(seed))
+		}
+		runner := policy.NewRunner(p, options...)
+		seed = runner.Seed()
+		runner.Run(func(result policy.Result) error {
+			add(result.Password)
+			return nil
+		})
+	}
+	return passwords, seed
+}
+
+func runCompare(args []string) {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	iterations := fs.Int("n", 200, "Number of passwords to generate for each category of both policies")
+	fs.Int64Var(&testSeed, "seed", 0, "Seed for the generated passwords")
+	examples := fs.Int("examples", 3, "Example passwords to show per rule")
+	against := fs.String("against", "", "Comma separated presets to compare with, all of them by default")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "compare [flags]", "Compares the policy with the built-in presets and lists the passwords where it is stricter or weaker than each of them, grouped by the rules that decided.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *iterations <= 0 || *examples < 0 {
+		usageError(fs, "-n must be positive and -examples can't be negative")
+	}
+
+	presets := policy.Presets()
+	if *against != "" {
+		presets = nil
+		for _, name := range strings.Split(*against, ",") {
+			preset, ok := policy.LookupPreset(strings.TrimSpace(name))
+			if !ok {
+				usageError(fs, "unknown preset %q, expected one of %s", name, strings.Join(policy.PresetNames(), ", "))
+			}
+			presets = append(presets, preset)
+		}
+	}
+
+	for i, preset := range presets {
+		if i > 0 {
+			fmt.Println()
+		}
+		passwords, seed := comparePasswords([]*policy.Policy{activePolicy, preset.Policy}, *iterations)
+		stricter := &comparison{groups: make(map[string][]string)}
+		weaker := &comparison{groups: make(map[string][]string)}
+		for _, password := range passwords {
+			ours, theirs := activePolicy.Validate(password), preset.Policy.Validate(password)
+			if !ours.Accepted && theirs.Accepted {
+				stricter.add(ours.Failures, password)
+			} else if ours.Accepted && !theirs.Accepted {
+				weaker.add(theirs.Failures, password)
+			}
+		}
+
+		fmt.Printf("%s compared with %s (%d passwords, seed %d)\n", activePolicy.Name, preset.Name, len(passwords), seed)
+		fmt.Printf("  %s\n", preset.Standard)
+		if stricter.total == 0 && weaker.total == 0 {
+			fmt.Println("  Same verdict on every password")
+			continue
+		}
+		stricter.print("Stricter, rejects passwords the preset accepts", "rejects for", *examples)
+		weaker.print("Weaker, accepts passwords the preset rejects", "accepts despite", *examples)
+	}
+}
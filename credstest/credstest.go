@@ -0,0 +1,128 @@
+// Package credstest runs the credstester generator categories against a password
+// validator from go test:
+//
+//	func TestValidator(t *testing.T) {
+//		credstest.Run(t, myValidator, credstest.Options{})
+//	}
+//
+// Every category becomes a subtest, so a single one can be rerun with
+// -run 'TestValidator/should-fail-upper'. Mismatches are reported with the seed
+// that reproduces them.
+package credstest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+// DefaultIterations is the number of passwords per category when Options leaves
+// Iterations at 0.
+const DefaultIterations = 1000
+
+// shortDivisor scales the iterations down under go test -short
+const shortDivisor = 10
+
+// maxReported caps the mismatches reported per category, the rest are counted
+const maxReported = 10
+
+// Options tunes Run. The zero value runs every category of the default policy.
+type Options struct {
+	Policy     *policy.Policy // Policy the validator implements, policy.Default() when nil
+	Iterations int            // Passwords per category, DefaultIterations when 0
+	Seed       int64          // Seed to reproduce a failing run, a new one when 0
+	Categories []string       // Categories to run, all of them when empty
+}
+
+// Validator reports whether a password is accepted.
+type Validator func(password string) bool
+
+// Run generates the passwords of every category and checks the validator gives
+// the verdict the policy expects. Under -short only a tenth of the iterations run.
+func Run(t *testing.T, validator Validator, options Options) {
+	t.Helper()
+	p := options.Policy
+	if p == nil {
+		p = policy.Default()
+	}
+	iterations := options.Iterations
+	if iterations <= 0 {
+		iterations = DefaultIterations
+	}
+	if testing.Short() {
+		iterations = max(iterations/shortDivisor, 1)
+	}
+	seed := options.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+
+	categories := p.Categories()
+	if len(options.Categories) > 0 {
+		categories = selectCategories(t, p, categories, options.Categories)
+	}
+
+	for _, category := range categories {
+		t.Run(category.Name, func(t *testing.T) {
+			t.Helper()
+			// Every category is seeded on its own, so a subtest repeats the passwords of a full run
+			runner := policy.NewRunner(p,
+				policy.WithCategories(category.Name),
+				policy.WithIterations(iterations),
+				policy.WithSeed(seed),
+				policy.WithValidator(validator),
+			)
+			mismatches := 0
+			runner.Run(func(result policy.Result) error {
+				if result.Expected == result.Actual {
+					return nil
+				}
+				mismatches += 1
+				if mismatches <= maxReported {
+					t.Errorf("%q: expected %s, got %s (seed %d)", result.Password, verdict(result.Expected), verdict(result.Actual), seed)
+				}
+				return nil
+			})
+			if mismatches > maxReported {
+				t.Errorf("%d more mismatches out of %d passwords (seed %d)", mismatches-maxReported, iterations, seed)
+			}
+			if mismatches > 0 {
+				t.Logf("reproduce with credstest.Options{Seed: %d, Iterations: %d}", seed, iterations)
+			}
+		})
+	}
+}
+
+// selectCategories keeps the named categories in the policy's order. Every name
+// has to be a category of the policy and be given once.
+func selectCategories(t *testing.T, p *policy.Policy, categories []policy.Category, names []string) []policy.Category {
+	t.Helper()
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		if wanted[name] {
+			t.Fatalf("credstest: category %s is given more than once", name)
+		}
+		wanted[name] = true
+	}
+	var selected []policy.Category
+	for _, category := range categories {
+		if wanted[category.Name] {
+			selected = append(selected, category)
+			delete(wanted, category.Name)
+		}
+	}
+	for _, name := range names {
+		if wanted[name] {
+			t.Fatalf("credstest: policy %s has no category %s", p.Name, name)
+		}
+	}
+	return selected
+}
+
+func verdict(accepted bool) string {
+	if accepted {
+		return "accept"
+	}
+	return "reject"
+}
@@ -0,0 +1,86 @@
+package credstest_test
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/TotallyMonica/testRegex/credstest"
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+func TestRunDefault(t *testing.T) {
+	credstest.Run(t, defaultPolicy.Accepts, credstest.Options{Iterations: 200})
+}
+
+var defaultPolicy = policy.Default()
+
+// ignoresSpecialChars is a broken validator that forgot the special character rule
+func ignoresSpecialChars(password string) bool {
+	for _, failure := range defaultPolicy.Validate(password).Failures {
+		if failure.Rule != "special-chars" {
+			return false
+		}
+	}
+	return true
+}
+
+// runChild reruns a test in a copy of this test binary with CREDSTEST_CHILD set,
+// for the runs that are meant to fail, and returns its output
+func runChild(t *testing.T, name string, child string) string {
+	t.Helper()
+	cmd := exec.Command(os.Args[0], "-test.run=^"+name+"$", "-test.v")
+	cmd.Env = append(os.Environ(), "CREDSTEST_CHILD="+child)
+	output, err := cmd.CombinedOutput()
+	if err == nil {
+		t.Fatalf("%s passed:\n%s", child, output)
+	}
+	return string(output)
+}
+
+// A failing Run fails the test it's given, so the broken validator runs in a
+// copy of this test binary
+func TestRunReportsMismatches(t *testing.T) {
+	if os.Getenv("CREDSTEST_CHILD") == "broken" {
+		credstest.Run(t, ignoresSpecialChars, credstest.Options{Iterations: 50, Seed: 1})
+		return
+	}
+
+	output := runChild(t, "TestRunReportsMismatches", "broken")
+	for _, want := range []string{
+		"--- FAIL: TestRunReportsMismatches/should-fail-special-chars",
+		"expected reject, got accept (seed 1)",
+		"reproduce with credstest.Options{Seed: 1, Iterations: 50}",
+		"--- PASS: TestRunReportsMismatches/should-pass",
+		"--- PASS: TestRunReportsMismatches/should-fail-upper",
+	} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output doesn't contain %q:\n%s", want, output)
+		}
+	}
+}
+
+func TestRunRejectsCategories(t *testing.T) {
+	children := map[string][]string{
+		"duplicate": {"should-pass", "should-pass"},
+		"unknown":   {"should-pass", "should-fail-blocklist"},
+	}
+	if names, ok := children[os.Getenv("CREDSTEST_CHILD")]; ok {
+		credstest.Run(t, defaultPolicy.Accepts, credstest.Options{Iterations: 1, Categories: names})
+		return
+	}
+
+	for child, want := range map[string]string{
+		"duplicate": "credstest: category should-pass is given more than once",
+		"unknown":   "credstest: policy default has no category should-fail-blocklist",
+	} {
+		if output := runChild(t, "TestRunRejectsCategories", child); !strings.Contains(output, want) {
+			t.Errorf("%s: output doesn't contain %q:\n%s", child, want, output)
+		}
+	}
+}
+
+func TestRunCategories(t *testing.T) {
+	credstest.Run(t, defaultPolicy.Accepts, credstest.Options{Iterations: 20, Categories: []string{"should-pass", "should-fail-length"}})
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"time"
+)
+
+const dashboardRefresh = 250 * time.Millisecond
+const dashboardFailures = 8
+const dashboardBarWidth = 30
+
+// dashboard is a full screen view of a run, fed by the loop writing results
+type dashboard struct {
+	mu         sync.Mutex
+	categories []string
+	perTest    int
+	done       map[string]int
+	mismatches map[string]int
+	latest     []testResults
+	started    time.Time
+	stopped    chan struct{}
+	finished   sync.WaitGroup
+	stopOnce   sync.Once
+}
+
+func newDashboard(categories []string, perTest int) *dashboard {
+	return &dashboard{
+		categories: categories,
+		perTest:    perTest,
+		done:       make(map[string]int),
+		mismatches: make(map[string]int),
+		started:    time.Now(),
+		stopped:    make(chan struct{}),
+	}
+}
+
+func (d *dashboard) record(result testResults) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.done[result.category] += 1
+	if result.expectedResult != result.actualResult {
+		d.mismatches[result.category] += 1
+		d.latest = append(d.latest, result)
+		if len(d.latest) > dashboardFailures {
+			d.latest = d.latest[1:]
+		}
+	}
+}
+
+func (d *dashboard) start() {
+	// Switch to the alternate screen and hide the cursor
+	fmt.Print("\033[?1049h\033[?25l")
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt)
+
+	d.finished.Add(1)
+	go func() {
+		defer d.finished.Done()
+		defer signal.Stop(interrupted)
+		ticker := time.NewTicker(dashboardRefresh)
+		defer ticker.Stop()
+		for {
+			d.draw()
+			select {
+			case <-ticker.C:
+			case <-interrupted:
+				d.restore()
+				os.Exit(130)
+			case <-d.stopped:
+				return
+			}
+		}
+	}()
+}
+
+func (d *dashboard) restore() {
+	fmt.Print("\033[?25h\033[?1049l")
+}
+
+// stop restores the terminal, it's safe to call more than once
+func (d *dashboard) stop() {
+	d.stopOnce.Do(func() {
+		close(d.stopped)
+		d.finished.Wait()
+		d.restore()
+	})
+}
+
+func progressBar(done int, total int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * dashboardBarWidth / total
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", dashboardBarWidth-filled) + "]"
+}
+
+func (d *dashboard) draw() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var b strings.Builder
+	elapsed := time.Since(d.started)
+	total := d.perTest * len(d.categories)
+	done := 0
+	mismatches := 0
+	for _, category := range d.categories {
+		done += d.done[category]
+		mismatches += d.mismatches[category]
+	}
+
+	throughput := float64(done) / elapsed.Seconds()
+	eta := "unknown"
+	if throughput > 0 {
+		eta = formatElapsed(time.Duration(float64(total-done) / throughput * float64(time.Second)))
+	}
+
+	b.WriteString("\033[H\033[2J")
+	fmt.Fprintf(&b, "%s run: %d out of %d passwords (%.2f%%)\n", programName, done, total, float64(done)/float64(total)*100)
+	fmt.Fprintf(&b, "Elapsed: %s  ETA: %s  Throughput: %.0f passwords/s\n\n", formatElapsed(elapsed), eta, throughput)
+	for _, category := range d.categories {
+		fmt.Fprintf(&b, "%-26s %s %6.2f%%  %d/%d  mismatches: %d\n", category, progressBar(d.done[category], d.perTest), float64(d.done[category])/float64(d.perTest)*100, d.done[category], d.perTest, d.mismatches[category])
+	}
+
+	fmt.Fprintf(&b, "\nMismatches: %d\n", mismatches)
+	if len(d.latest) > 0 {
+		b.WriteString("Latest failures:\n")
+		for i := len(d.latest) - 1; i >= 0; i-- {
+			result := d.latest[i]
+			fmt.Fprintf(&b, "  %-26s %q (expected %t, got %t)\n", result.category, result.testedPassword, result.expectedResult, result.actualResult)
+		}
+	}
+	fmt.Print(b.String())
+}
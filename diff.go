@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+)
+
+func flipDirection(accepted bool) string {
+	if accepted {
+		return "rejected -> accepted"
+	}
+	return "accepted -> rejected"
+}
+
+// verdictKey identifies a row, the same password can be generated for several
+// categories. Rows of the same key are paired in the order they appear.
+type verdictKey struct {
+	category string
+	password string
+}
+
+func newVerdictKey(result testResults, withCategory bool) verdictKey {
+	if withCategory {
+		return verdictKey{category: result.category, password: result.testedPassword}
+	}
+	return verdictKey{password: result.testedPassword}
+}
+
+// keyVerdicts reads a results file into queues of rows by key. Only this side of
+// the comparison is kept in memory, the other is streamed past it.
+func keyVerdicts(path string, withCategory bool) (map[verdictKey][]testResults, error) {
+	keyed := make(map[verdictKey][]testResults)
+	err := readResults(path, func(result testResults) {
+		key := newVerdictKey(result, withCategory)
+		keyed[key] = append(keyed[key], result)
+	})
+	return keyed, err
+}
+
+// hasCategories reports whether any row of a results file has a category, older
+// results files have none
+func hasCategories(path string) (bool, error) {
+	found := false
+	err := readResults(path, func(result testResults) {
+		found = found || result.category != ""
+	})
+	return found, err
+}
+
+func runDiff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	maxFlips := fs.Int("max-flips", 0, "Exit with a non-zero status when more than this many verdicts flipped")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "diff [flags] OLD.csv [NEW.csv]", "Compares the actual verdicts of two results files. With only one file, its passwords are re-validated against the current policy.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(exitUsageError)
+	}
+
+	// Categories are left out of the keys unless both files have them
+	withCategory, err := hasCategories(fs.Arg(0))
+	if err == nil && withCategory && fs.NArg() == 2 {
+		withCategory, err = hasCategories(fs.Arg(1))
+	}
+	if err != nil {
+		fatalIO("Error while reading results: ", err)
+	}
+	oldVerdicts, err := keyVerdicts(fs.Arg(0), withCategory)
+	if err != nil {
+		fatalIO("Error while reading results: ", err)
+	}
+
+	// Group the flips by category and direction
+	groups := make(map[string][]string)
+	compared := 0
+	onlyNew := 0
+	flips := 0
+	compare := func(newResult testResults) {
+		key := newVerdictKey(newResult, withCategory)
+		queue := oldVerdicts[key]
+		if len(queue) == 0 {
+			onlyNew += 1
+			return
+		}
+		oldResult := queue[0]
+		if len(queue) == 1 {
+			delete(oldVerdicts, key)
+		} else {
+			oldVerdicts[key] = queue[1:]
+		}
+		compared += 1
+		if oldResult.actualResult == newResult.actualResult {
+			return
+		}
+		category := newResult.category
+		if category == "" {
+			category = oldResult.category
+		}
+		group := categoryName(category) + ": " + flipDirection(newResult.actualResult)
+		groups[group] = append(groups[group], key.password)
+		flips += 1
+	}
+
+	// Either stream the newer verdicts or rerun the old passwords through the current regexes
+	if fs.NArg() == 2 {
+		err = readResults(fs.Arg(1), compare)
+	} else {
+		err = readResults(fs.Arg(0), func(result testResults) {
+			result.actualResult = activePolicy.Accepts(result.testedPassword)
+			compare(result)
+		})
+	}
+	if err != nil {
+		fatalIO("Error while reading results: ", err)
+	}
+	onlyOld := 0
+	for _, queue := range oldVerdicts {
+		onlyOld += len(queue)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		group := groups[key]
+		sort.Strings(group)
+		fmt.Printf("--- %s --- %d passwords\n", key, len(group))
+		for _, password := range group {
+			fmt.Printf("%s\n", password)
+		}
+	}
+
+	fmt.Printf("Passwords compared: %d\n", compared)
+	fmt.Printf("Verdicts flipped: %d\n", flips)
+	if onlyOld > 0 || onlyNew > 0 {
+		fmt.Printf("Passwords only in the old results: %d, only in the new results: %d\n", onlyOld, onlyNew)
+	}
+
+	if flips > *maxFlips {
+		fmt.Printf("Number of flipped verdicts exceeds the allowed %d\n", *maxFlips)
+		os.Exit(exitThresholdExceeded)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// evaluation treats an accepted password as the positive class, so a false accept
+// is an illegal password the policy let through (security) and a false reject is a
+// legal password it refused (usability)
+type evaluation struct {
+	counts       map[string]*categoryCounts
+	falseAccepts []testResults
+	falseRejects []testResults
+	known        []testResults // Failures accepted by the baseline
+}
+
+func newEvaluation() *evaluation {
+	return &evaluation{counts: make(map[string]*categoryCounts)}
+}
+
+// add tallies a result and records it if it failed
+func (e *evaluation) add(result testResults) {
+	if e.counts[result.category] == nil {
+		e.counts[result.category] = &categoryCounts{}
+	}
+	e.counts[result.category].add(result.expectedResult, result.actualResult)
+	if result.expectedResult == result.actualResult {
+		return
+	}
+	if knownBaseline != nil && knownBaseline.known(result) {
+		e.known = append(e.known, result)
+		return
+	}
+	if result.actualResult {
+		e.falseAccepts = append(e.falseAccepts, result)
+	} else {
+		e.falseRejects = append(e.falseRejects, result)
+	}
+}
+
+func (e *evaluation) totals() categoryCounts {
+	var totals categoryCounts
+	for _, c := range e.counts {
+		totals.trueAccepts += c.trueAccepts
+		totals.trueRejects += c.trueRejects
+		totals.falseAccepts += c.falseAccepts
+		totals.falseRejects += c.falseRejects
+	}
+	return totals
+}
+
+func percent(numerator int, denominator int) string {
+	if denominator == 0 {
+		return "n/a"
+	}
+	return fmt.Sprintf("%.4g%%", float64(numerator)/float64(denominator)*100)
+}
+
+func describeResult(result testResults) string {
+	if result.reason != "" {
+		return categoryName(result.category) + ": " + result.reason
+	}
+	return categoryName(result.category)
+}
+
+func categoryName(category string) string {
+	if category == "" {
+		return "uncategorized"
+	}
+	return category
+}
+
+func (e *evaluation) report() {
+	if len(e.falseAccepts) > 0 {
+		fmt.Printf("--- FALSE ACCEPTS (SECURITY) --- %d illegal passwords were accepted\n", len(e.falseAccepts))
+		if listFailures {
+			for _, result := range e.falseAccepts {
+				fmt.Printf("%s was accepted but should have been rejected (%s)\n", result.testedPassword, describeResult(result))
+			}
+		}
+		printClusters(e.falseAccepts)
+	}
+	if len(e.falseRejects) > 0 {
+		fmt.Printf("--- FALSE REJECTS (USABILITY) --- %d legal passwords were rejected\n", len(e.falseRejects))
+		if listFailures {
+			for _, result := range e.falseRejects {
+				fmt.Printf("%s was rejected but should have been accepted (%s)\n", result.testedPassword, describeResult(result))
+			}
+		}
+		printClusters(e.falseRejects)
+	}
+
+	if knownBaseline != nil {
+		fmt.Printf("--- KNOWN FAILURES --- %d failures are accepted by the baseline\n", len(e.known))
+		printClusters(e.known)
+		fixed := knownBaseline.fixed()
+		fmt.Printf("--- FIXED FAILURES --- %d baseline entries no longer fail\n", len(fixed))
+		for _, entry := range fixed {
+			fmt.Printf("%s %s %q\n", entry.failure, entry.kind, entry.value)
+		}
+		fmt.Printf("New failures: %d\n", len(e.falseAccepts)+len(e.falseRejects))
+	}
+
+	totals := e.totals()
+	expectedAccepts := totals.trueAccepts + totals.falseRejects
+	expectedRejects := totals.trueRejects + totals.falseAccepts
+
+	fmt.Printf("Total number of tests ran: %d\n", totals.total())
+	fmt.Printf("Number of passing tests: %d (%s)\n", totals.total()-totals.failed(), percent(totals.total()-totals.failed(), totals.total()))
+	fmt.Printf("False accepts: %d (%s of passwords that should be rejected)\n", totals.falseAccepts, percent(totals.falseAccepts, expectedRejects))
+	fmt.Printf("False rejects: %d (%s of passwords that should be accepted)\n", totals.falseRejects, percent(totals.falseRejects, expectedAccepts))
+	fmt.Printf("Precision: %s\n", percent(totals.trueAccepts, totals.trueAccepts+totals.falseAccepts))
+	fmt.Printf("Recall: %s\n", percent(totals.trueAccepts, expectedAccepts))
+
+	// Confusion matrix
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\tAccepted\tRejected\t")
+	fmt.Fprintf(w, "Should accept\t%d\t%d\t\n", totals.trueAccepts, totals.falseRejects)
+	fmt.Fprintf(w, "Should reject\t%d\t%d\t\n", totals.falseAccepts, totals.trueRejects)
+	fmt.Fprintln(w)
+
+	// Per category breakdown
+	fmt.Fprintln(w, "Category\tTotal\tPassed\tPass rate\tFalse accepts\tFalse rejects\t")
+	for _, category := range sortedCategories(e.counts) {
+		c := e.counts[category]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\t%d\t\n", categoryName(category), c.total(), c.total()-c.failed(), percent(c.total()-c.failed(), c.total()), c.falseAccepts, c.falseRejects)
+	}
+	err := w.Flush()
+	if err != nil {
+		fatalIO("Error while writing report: ", err)
+	}
+}
+
+func runEvals(start time.Time) {
+	evalStart := time.Now()
+	e := newEvaluation()
+
+	if doEvals {
+		// Totals come from the counts file when there is one, since failures only runs don't keep passing rows
+		counts, err := readCounts(countsFile)
+		if err != nil {
+			fatalIO("Error while reading counts: ", err)
+		}
+
+		// Get all the tests
+		err = readResults(resultsFile, e.add)
+		if err != nil {
+			fatalIO("Error while parsing CSV: ", err)
+		}
+
+		// A counts file left over from another run would report the wrong totals
+		if counts != nil {
+			if mismatch := countsMismatch(counts, e.counts); mismatch != "" {
+				fmt.Fprintf(os.Stderr, "Warning: ignoring %s, it doesn't belong to %s: %s\n", countsFile, resultsFile, mismatch)
+			} else {
+				e.counts = counts
+			}
+		}
+	}
+
+	// Labeled passwords are checked now and reported like any other category
+	if goldenFile != "" {
+		err := readGolden(goldenFile, goldenFileFormat, e.add)
+		if err != nil {
+			fatalIO("Error while reading golden file: ", err)
+		}
+	}
+	e.report()
+
+	t := time.Now()
+	elapsed := t.Sub(evalStart)
+
+	fmt.Printf("Total time to evaluate test results: %s\n", formatElapsed(elapsed))
+
+	t = time.Now()
+	elapsed = t.Sub(start)
+	fmt.Printf("Overall time to evaluate test results: %s\n", formatElapsed(elapsed))
+
+	// Failures accepted by the baseline don't count against the thresholds
+	if e.checkThresholds() {
+		os.Exit(exitThresholdExceeded)
+	}
+	os.Exit(exitSuccess)
+}
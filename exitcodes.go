@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// Exit codes shared by every mode, so scripts can tell a failing policy apart
+// from a broken invocation
+const (
+	exitSuccess           = 0 // Everything ran and no threshold was exceeded
+	exitThresholdExceeded = 1 // Failures, flips or rates went over their allowed thresholds
+	exitUsageError        = 2 // Invalid flags or arguments
+	exitIOError           = 3 // A file couldn't be read, parsed or written
+)
+
+const exitCodesHelp = `Exit codes:
+  0  success
+  1  a failure threshold was exceeded
+  2  usage error
+  3  I/O error while reading or writing files
+`
+
+func fatalIO(v ...any) {
+	log.Print(v...)
+	os.Exit(exitIOError)
+}
+
+func fatalIOf(format string, v ...any) {
+	log.Printf(format, v...)
+	os.Exit(exitIOError)
+}
+
+func printUsage(fs *flag.FlagSet, usage string, description string) {
+	fmt.Fprintf(fs.Output(), "Usage: %s %s\n", programName, usage)
+	if description != "" {
+		fmt.Fprintln(fs.Output(), description)
+	}
+	fs.PrintDefaults()
+	fmt.Fprint(fs.Output(), exitCodesHelp)
+}
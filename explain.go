@@ -0,0 +1,210 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	colorReset   = "\033[0m"
+	colorIllegal = "\033[97;41m"
+)
+
+// classColors are used for the character classes of the policy in order
+var classColors = []string{"\033[34m", "\033[32m", "\033[33m", "\033[36m", "\033[35m"}
+
+func classColor(i int) string {
+	return classColors[i%len(classColors)]
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// useColor follows -color, where auto only colors terminals and respects NO_COLOR
+func useColor(mode string) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+}
+
+// matchedSpans lists the 1-based character ranges a regex matches, merging
+// matches that touch
+func matchedSpans(regex *regexp.Regexp, passwd string) []string {
+	var spans []string
+	lastStart, lastEnd := -1, -1
+	flush := func() {
+		if lastStart == -1 {
+			return
+		}
+		start := utf8.RuneCountInString(passwd[:lastStart]) + 1
+		end := utf8.RuneCountInString(passwd[:lastEnd])
+		span := strconv.Itoa(start)
+		if end > start {
+			span += "-" + strconv.Itoa(end)
+		}
+		spans = append(spans, fmt.Sprintf("%q at %s", passwd[lastStart:lastEnd], span))
+	}
+	for _, match := range regex.FindAllStringIndex(passwd, -1) {
+		if match[0] == lastEnd {
+			lastEnd = match[1]
+			continue
+		}
+		flush()
+		lastStart, lastEnd = match[0], match[1]
+	}
+	flush()
+	return spans
+}
+
+// displayRune shows control and other invisible characters escaped
+func displayRune(r rune) string {
+	if unicode.IsGraphic(r) && r != ' ' {
+		return string(r)
+	}
+	quoted := strconv.QuoteRune(r)
+	return quoted[1 : len(quoted)-1]
+}
+
+func renderPassword(passwd string, color bool) (string, string) {
+	var rendered, markers strings.Builder
+	for _, r := range passwd {
+		char := displayRune(r)
+		s := string(r)
+		code := ""
+		marker := " "
+		if !activePolicy.Allowed(r) {
+			code = colorIllegal
+			marker = "^"
+		} else {
+			for i := range activePolicy.Classes {
+				if activePolicy.Classes[i].Regexp().MatchString(s) {
+					code = classColor(i)
+					break
+				}
+			}
+		}
+
+		if color && code != "" {
+			rendered.WriteString(code + char + colorReset)
+		} else {
+			rendered.WriteString(char)
+		}
+		markers.WriteString(strings.Repeat(marker, utf8.RuneCountInString(char)))
+	}
+	return rendered.String(), strings.TrimRight(markers.String(), " ")
+}
+
+func runExplain(args []string) {
+	fs := flag.NewFlagSet("explain", flag.ExitOnError)
+	colorMode := fs.String("color", "auto", "Color the password: auto, always or never")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "explain [flags] PASSWORD", "Shows which rules of the policy a password meets, what each pattern matched and which characters aren't allowed.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() != 1 {
+		usageError(fs, "expected exactly one password")
+	}
+	if *colorMode != "auto" && *colorMode != "always" && *colorMode != "never" {
+		usageError(fs, "unknown -color %q, expected auto, always or never", *colorMode)
+	}
+	passwd := fs.Arg(0)
+	color := useColor(*colorMode)
+
+	rendered, markers := renderPassword(passwd, color)
+	fmt.Printf("Password: %s\n", rendered)
+	if strings.Contains(markers, "^") {
+		fmt.Printf("          %s\n", markers)
+	}
+	if color {
+		legend := make([]string, 0, len(activePolicy.Classes)+1)
+		for i, class := range activePolicy.Classes {
+			legend = append(legend, classColor(i)+class.Name+colorReset)
+		}
+		legend = append(legend, colorIllegal+"illegal"+colorReset)
+		fmt.Printf("Legend:   %s\n", strings.Join(legend, " "))
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Rule\tPattern\tResult\tMatches\t")
+	for _, class := range activePolicy.Classes {
+		spans := matchedSpans(class.Regexp(), passwd)
+		found := len(class.Regexp().FindAllStringIndex(passwd, -1))
+		result := "ok"
+		if found < class.Min {
+			result = "FAIL"
+		}
+		name := class.Name
+		if class.Min > 1 {
+			name = fmt.Sprintf("%s (at least %d)", name, class.Min)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t\n", name, class.Regexp(), result, strings.Join(spans, ", "))
+	}
+
+	// The alphabet and the length are checked by the same regex, so they're reported apart
+	length := utf8.RuneCountInString(passwd)
+	credits := activePolicy.Credits(passwd)
+	result := "ok"
+	bounds := fmt.Sprintf("{%d,}", activePolicy.MinLength)
+	if activePolicy.MaxLength > 0 {
+		bounds = fmt.Sprintf("{%d,%d}", activePolicy.MinLength, activePolicy.MaxLength)
+	}
+	if length+credits < activePolicy.MinLength || (activePolicy.MaxLength > 0 && length > activePolicy.MaxLength) {
+		result = "FAIL"
+	}
+	counted := fmt.Sprintf("%d characters", length)
+	if credits > 0 {
+		counted = fmt.Sprintf("%d characters + %d credits", length, credits)
+	}
+	fmt.Fprintf(w, "Length\t%s\t%s\t%s\t\n", bounds, result, counted)
+
+	result = "ok"
+	breaking := "none"
+	position := 0
+	for _, r := range passwd {
+		position += 1
+		if !activePolicy.Allowed(r) {
+			result = "FAIL"
+			breaking = fmt.Sprintf("first illegal %q at %d", r, position)
+			break
+		}
+	}
+	alphabet := activePolicy.Alphabet
+	if alphabet == "" {
+		alphabet = "any"
+	}
+	fmt.Fprintf(w, "Allowed characters\t%s\t%s\t%s\t\n", alphabet, result, breaking)
+
+	result = "ok"
+	if !activePolicy.Accepts(passwd) {
+		result = "FAIL"
+	}
+	fmt.Fprintf(w, "Whole password\t%s\t%s\t\t\n", activePolicy.ValidPattern(), result)
+	w.Flush()
+	fmt.Println()
+
+	verdict := activePolicy.Validate(passwd)
+	if verdict.Accepted {
+		fmt.Println("Verdict: accept")
+		return
+	}
+	fmt.Println("Verdict: reject")
+	for _, failure := range verdict.Failures {
+		fmt.Printf("  - %s\n", failure.Message)
+	}
+}
@@ -0,0 +1,524 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	"github.com/TotallyMonica/testRegex/policy"
+	"github.com/dop251/goja"
+)
+
+var exportFormats = []string{"html", "json-schema", "openapi", "python"}
+
+// exportDialect is how a regex flavor writes characters inside a class
+type exportDialect struct {
+	escaped string // Characters that need a backslash
+	escape  func(r rune) string
+}
+
+// jsDialect works with and without the u and v flags. The v flag browsers use for
+// pattern attributes reserves ( ) [ ] { } / - \ |, which are escaped here too.
+var jsDialect = exportDialect{
+	escaped: `\]^-[/(){}|$.*+?`,
+	escape: func(r rune) string {
+		if r > 0xffff {
+			return fmt.Sprintf(`\u{%X}`, r)
+		}
+		return fmt.Sprintf(`\u%04X`, r)
+	},
+}
+
+// pythonDialect also escapes the characters re warns about when doubled
+var pythonDialect = exportDialect{
+	escaped: `\]^-[&~|`,
+	escape: func(r rune) string {
+		if r > 0xffff {
+			return fmt.Sprintf(`\U%08X`, r)
+		}
+		return fmt.Sprintf(`\u%04X`, r)
+	},
+}
+
+func (d exportDialect) char(r rune) string {
+	if !unicode.IsPrint(r) {
+		return d.escape(r)
+	} else if strings.ContainsRune(d.escaped, r) {
+		return `\` + string(r)
+	}
+	return string(r)
+}
+
+// class writes a character class, nil ranges match every character
+func (d exportDialect) class(ranges []policy.Range, negated bool) string {
+	if ranges == nil || (len(ranges) == 1 && ranges[0].Lo == 0 && ranges[0].Hi == unicode.MaxRune) {
+		if negated {
+			return `[^\s\S]`
+		}
+		return `[\s\S]`
+	}
+	var b strings.Builder
+	b.WriteString("[")
+	if negated {
+		b.WriteString("^")
+	}
+	for _, r := range ranges {
+		b.WriteString(d.char(r.Lo))
+		if r.Hi == r.Lo+1 {
+			b.WriteString(d.char(r.Hi))
+		} else if r.Hi > r.Lo {
+			b.WriteString("-" + d.char(r.Hi))
+		}
+	}
+	b.WriteString("]")
+	return b.String()
+}
+
+// maxExportedSequences is the most sequences the pattern lists one by one
+const maxExportedSequences = 2000
+
+// sequenceWindows lists every run the max-sequence rule rejects, ascending and
+// descending. Merged ranges hold the longest runs of allowed code points, so each
+// run lies within one range. It fails when the alphabet is unbounded or too big.
+func sequenceWindows() ([][]rune, bool) {
+	ranges := activePolicy.AlphabetRanges()
+	length := activePolicy.MaxSequence + 1
+	if ranges == nil {
+		return nil, false
+	}
+	var windows [][]rune
+	for _, r := range ranges {
+		for start := r.Lo; start+rune(length)-1 <= r.Hi; start++ {
+			if len(windows) >= maxExportedSequences {
+				return nil, false
+			}
+			up := make([]rune, length)
+			down := make([]rune, length)
+			for i := range up {
+				up[i] = start + rune(i)
+				down[length-1-i] = up[i]
+			}
+			windows = append(windows, up, down)
+		}
+	}
+	return windows, true
+}
+
+// runRules writes negative lookaheads for the repeat, sequence and keyboard walk
+// rules, anywhere in the password
+func (d exportDialect) runRules() string {
+	any := d.class(nil, false)
+	var b strings.Builder
+	if activePolicy.MaxRepeat > 0 {
+		fmt.Fprintf(&b, "(?!%s*(%s)\\1{%d})", any, any, activePolicy.MaxRepeat)
+	}
+	var runs []string
+	if windows, ok := sequenceWindows(); activePolicy.MaxSequence > 0 && ok {
+		for _, window := range windows {
+			var run strings.Builder
+			for _, c := range window {
+				run.WriteString(d.class([]policy.Range{{Lo: c, Hi: c}}, false))
+			}
+			runs = append(runs, run.String())
+		}
+	}
+	if activePolicy.MaxKeyboardWalk > 0 {
+		for _, walk := range policy.KeyboardWalks(activePolicy.MaxKeyboardWalk + 1) {
+			var run strings.Builder
+			for _, key := range walk {
+				var keyRanges []policy.Range
+				for _, c := range key {
+					keyRanges = append(keyRanges, policy.Range{Lo: c, Hi: c})
+				}
+				run.WriteString(d.class(keyRanges, false))
+			}
+			runs = append(runs, run.String())
+		}
+	}
+	if len(runs) > 0 {
+		fmt.Fprintf(&b, "(?!%s*(?:%s))", any, strings.Join(runs, "|"))
+	}
+	return b.String()
+}
+
+// pattern writes the whole policy as one unanchored regex: a lookahead for every
+// required class and the run rules, then the alphabet with the length bounds
+func (d exportDialect) pattern() string {
+	var b strings.Builder
+	b.WriteString(d.runRules())
+	for _, class := range activePolicy.Classes {
+		if class.Min == 0 {
+			continue
+		}
+		lookahead := d.class(class.Ranges(), true) + "*" + d.class(class.Ranges(), false)
+		if class.Min == 1 {
+			fmt.Fprintf(&b, "(?=%s)", lookahead)
+		} else {
+			fmt.Fprintf(&b, "(?=(?:%s){%d})", lookahead, class.Min)
+		}
+	}
+	b.WriteString(d.class(activePolicy.AlphabetRanges(), false))
+	if activePolicy.MaxLength > 0 {
+		fmt.Fprintf(&b, "{%d,%d}", exportMinLength(), activePolicy.MaxLength)
+	} else {
+		fmt.Fprintf(&b, "{%d,}", exportMinLength())
+	}
+	return b.String()
+}
+
+// totalCredit is the most characters credits can make up for
+func totalCredit() int {
+	credit := 0
+	for _, class := range activePolicy.Classes {
+		credit += class.Credit
+	}
+	return credit
+}
+
+// exportMinLength is the minimum length the exports ask for. With credits it's the
+// shortest length full credits can make up for, since a regex can't count them.
+func exportMinLength() int {
+	return max(activePolicy.MinLength-totalCredit(), 0)
+}
+
+// policySummary describes the policy in a sentence, for titles and descriptions
+func policySummary() string {
+	summary := fmt.Sprintf("At least %d characters", activePolicy.MinLength)
+	if activePolicy.MaxLength > 0 {
+		summary = fmt.Sprintf("Between %d and %d characters", activePolicy.MinLength, activePolicy.MaxLength)
+	}
+	var required []string
+	for _, class := range activePolicy.Classes {
+		if class.Min > 0 {
+			description := class.Description
+			if description == "" {
+				description = class.Name + " character"
+			}
+			required = append(required, fmt.Sprintf("%d %s", class.Min, description))
+		}
+	}
+	if len(required) > 0 {
+		summary += ", with at least " + strings.Join(required, ", ")
+	}
+	if activePolicy.MaxRepeat > 0 {
+		summary += fmt.Sprintf(", no character more than %d times in a row", activePolicy.MaxRepeat)
+	}
+	if activePolicy.MaxSequence > 0 {
+		summary += fmt.Sprintf(", no sequences longer than %d like abcd", activePolicy.MaxSequence)
+	}
+	if activePolicy.MaxKeyboardWalk > 0 {
+		summary += fmt.Sprintf(", no keyboard walks longer than %d like qwer", activePolicy.MaxKeyboardWalk)
+	}
+	return summary
+}
+
+type jsonSchema struct {
+	Schema      string `json:"$schema"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Type        string `json:"type"`
+	MinLength   int    `json:"minLength"`
+	MaxLength   int    `json:"maxLength,omitempty"`
+	Pattern     string `json:"pattern"`
+}
+
+func exportPolicy(format string) string {
+	switch format {
+	case "html":
+		maxLength := ""
+		if activePolicy.MaxLength > 0 {
+			maxLength = fmt.Sprintf(` maxlength="%d"`, activePolicy.MaxLength)
+		}
+		// Browsers anchor the pattern attribute themselves
+		return fmt.Sprintf(`<input type="password" name="password" required minlength="%d"%s pattern="%s" title="%s">`+"\n", exportMinLength(), maxLength, html.EscapeString(jsDialect.pattern()), html.EscapeString(policySummary()))
+	case "json-schema":
+		schema := jsonSchema{
+			Schema:      "https://json-schema.org/draft/2020-12/schema",
+			Title:       "Password (" + activePolicy.Name + " policy)",
+			Description: policySummary(),
+			Type:        "string",
+			MinLength:   exportMinLength(),
+			MaxLength:   activePolicy.MaxLength,
+			Pattern:     "^" + jsDialect.pattern() + "$",
+		}
+		var b strings.Builder
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(schema)
+		return b.String()
+	case "openapi":
+		// Double quoted YAML understands the same escapes as a Go string
+		var b strings.Builder
+		b.WriteString("components:\n  schemas:\n    Password:\n      type: string\n      format: password\n")
+		fmt.Fprintf(&b, "      description: %s\n", strconv.Quote(policySummary()))
+		fmt.Fprintf(&b, "      minLength: %d\n", exportMinLength())
+		if activePolicy.MaxLength > 0 {
+			fmt.Fprintf(&b, "      maxLength: %d\n", activePolicy.MaxLength)
+		}
+		fmt.Fprintf(&b, "      pattern: %s\n", strconv.Quote("^"+jsDialect.pattern()+"$"))
+		return b.String()
+	case "python":
+		// fullmatch instead of anchors, $ would accept a trailing newline
+		var b strings.Builder
+		fmt.Fprintf(&b, "# %s password policy: %s\nimport re\n\n", activePolicy.Name, policySummary())
+		fmt.Fprintf(&b, "PASSWORD_PATTERN = re.compile(%s)\n\n\n", strconv.Quote(pythonDialect.pattern()))
+		b.WriteString("def is_valid_password(password: str) -> bool:\n    return PASSWORD_PATTERN.fullmatch(password) is not None\n")
+		return b.String()
+	}
+	return ""
+}
+
+// exportChecker evaluates an export the way its consumer would
+type exportChecker func(export string, passwords []string) ([]bool, error)
+
+var htmlPatternRegex = regexp.MustCompile(`pattern="([^"]*)"`)
+var openAPIPatternRegex = regexp.MustCompile(`(?m)^\s*pattern: (".*")$`)
+
+// jsCheck runs a regex through the embedded JavaScript engine with the u flag,
+// the closest it has to the v flag of pattern attributes
+func jsCheck(pattern string, minLength int, maxLength int, passwords []string) ([]bool, error) {
+	vm := goja.New()
+	re, err := vm.New(vm.Get("RegExp"), vm.ToValue(pattern), vm.ToValue("u"))
+	if err != nil {
+		return nil, err
+	}
+	test, _ := goja.AssertFunction(re.Get("test"))
+	verdicts := make([]bool, len(passwords))
+	for i, password := range passwords {
+		result, err := test(re, vm.ToValue(password))
+		if err != nil {
+			return nil, err
+		}
+		length := len([]rune(password))
+		verdicts[i] = result.ToBoolean() && length >= minLength && (maxLength == 0 || length <= maxLength)
+	}
+	return verdicts, nil
+}
+
+var exportCheckers = map[string]exportChecker{
+	"html": func(export string, passwords []string) ([]bool, error) {
+		match := htmlPatternRegex.FindStringSubmatch(export)
+		if match == nil {
+			return nil, errors.New("no pattern attribute")
+		}
+		verdicts, err := jsCheck("^(?:"+html.UnescapeString(match[1])+")$", 0, 0, passwords)
+		if err != nil {
+			return nil, err
+		}
+		// minlength and maxlength count UTF-16 code units
+		for i, password := range passwords {
+			units := len(utf16.Encode([]rune(password)))
+			if units < exportMinLength() || (activePolicy.MaxLength > 0 && units > activePolicy.MaxLength) {
+				verdicts[i] = false
+			}
+		}
+		return verdicts, nil
+	},
+	"json-schema": func(export string, passwords []string) ([]bool, error) {
+		var schema jsonSchema
+		err := json.Unmarshal([]byte(export), &schema)
+		if err != nil {
+			return nil, err
+		}
+		return jsCheck(schema.Pattern, schema.MinLength, schema.MaxLength, passwords)
+	},
+	"openapi": func(export string, passwords []string) ([]bool, error) {
+		match := openAPIPatternRegex.FindStringSubmatch(export)
+		if match == nil {
+			return nil, errors.New("no pattern")
+		}
+		pattern, err := strconv.Unquote(match[1])
+		if err != nil {
+			return nil, err
+		}
+		return jsCheck(pattern, exportMinLength(), activePolicy.MaxLength, passwords)
+	},
+	"python": func(export string, passwords []string) ([]bool, error) {
+		python, err := exec.LookPath("python3")
+		if err != nil {
+			return nil, errExportSkipped
+		}
+		harness := export + "\n\nimport json, sys\nfor line in sys.stdin.buffer:\n    print(int(is_valid_password(json.loads(line.decode('utf-8')))))\n"
+		var input strings.Builder
+		for _, password := range passwords {
+			encoded, _ := json.Marshal(password)
+			input.Write(encoded)
+			input.WriteString("\n")
+		}
+		cmd := exec.Command(python, "-c", harness)
+		cmd.Stdin = strings.NewReader(input.String())
+		cmd.Stderr = os.Stderr
+		output, err := cmd.Output()
+		if err != nil {
+			return nil, err
+		}
+		lines := strings.Fields(string(output))
+		if len(lines) != len(passwords) {
+			return nil, fmt.Errorf("python printed %d verdicts for %d passwords", len(lines), len(passwords))
+		}
+		verdicts := make([]bool, len(passwords))
+		for i, line := range lines {
+			verdicts[i] = line == "1"
+		}
+		return verdicts, nil
+	},
+}
+
+var errExportSkipped = errors.New("skipped")
+
+// selfCheckExport compares each export against the policy on generated and probe
+// passwords, and returns whether they all agree
+func selfCheckExport(formats []string, iterations int) bool {
+	passwords := jsProbes()
+	runner := policy.NewRunner(activePolicy, policy.WithIterations(iterations))
+	runner.Run(func(result policy.Result) error {
+		passwords = append(passwords, result.Password)
+		return nil
+	})
+
+	omitted := make(map[string]bool)
+	for _, limitation := range exportLimitations() {
+		for _, rule := range limitation.rules {
+			omitted[rule] = true
+		}
+	}
+
+	agree := true
+	for _, format := range formats {
+		verdicts, err := exportCheckers[format](exportPolicy(format), passwords)
+		if err == errExportSkipped {
+			fmt.Fprintf(os.Stderr, "%s: skipped, python3 isn't installed\n", format)
+			continue
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: FAIL, couldn't evaluate the export: %s\n", format, err)
+			agree = false
+			continue
+		}
+		var mismatches, expected []string
+		for i, password := range passwords {
+			if verdicts[i] == activePolicy.Accepts(password) {
+				continue
+			}
+			mismatch := fmt.Sprintf("%q (policy %s, export %s)", password, jsVerdict(!verdicts[i]), jsVerdict(verdicts[i]))
+			if rules, ok := onlyOmittedRules(password, omitted); verdicts[i] && ok {
+				expected = append(expected, mismatch+": expected (omitted rule "+strings.Join(rules, ", ")+")")
+			} else {
+				mismatches = append(mismatches, mismatch)
+			}
+		}
+		agreed := len(passwords) - len(mismatches) - len(expected)
+		if len(mismatches) == 0 {
+			fmt.Fprintf(os.Stderr, "%s: ok, agrees on %d out of %d passwords (seed %d)\n", format, agreed, len(passwords), runner.Seed())
+		} else {
+			agree = false
+			fmt.Fprintf(os.Stderr, "%s: FAIL, disagrees on %d out of %d passwords (seed %d)\n", format, len(mismatches), len(passwords), runner.Seed())
+			for _, mismatch := range mismatches[:min(len(mismatches), 5)] {
+				fmt.Fprintf(os.Stderr, "  %s\n", mismatch)
+			}
+		}
+		if len(expected) > 0 {
+			fmt.Fprintf(os.Stderr, "  %d passwords break only rules the export leaves out, like\n", len(expected))
+			for _, mismatch := range expected[:min(len(expected), 3)] {
+				fmt.Fprintf(os.Stderr, "  %s\n", mismatch)
+			}
+		}
+	}
+	return agree
+}
+
+// onlyOmittedRules reports whether every rule a password breaks is one the exports
+// leave out, and lists them
+func onlyOmittedRules(password string, omitted map[string]bool) ([]string, bool) {
+	var rules []string
+	for _, failure := range activePolicy.Validate(password).Failures {
+		if !omitted[failure.Rule] {
+			return nil, false
+		}
+		if !slices.Contains(rules, failure.Rule) {
+			rules = append(rules, failure.Rule)
+		}
+	}
+	return rules, len(rules) > 0
+}
+
+// exportLimitation is a rule of the policy a single regex with length bounds
+// can't express
+type exportLimitation struct {
+	description string
+	rules       []string // Failure rules of the validator it covers
+}
+
+func exportLimitations() []exportLimitation {
+	var limitations []exportLimitation
+	if credit := totalCredit(); credit > 0 {
+		description := fmt.Sprintf("length credits, they ask for at least %d characters as if every credit was earned", exportMinLength())
+		limitations = append(limitations, exportLimitation{description, []string{"min-length"}})
+	}
+	if activePolicy.MinClasses > 0 {
+		limitations = append(limitations, exportLimitation{"minimum number of classes", []string{"min-classes"}})
+	}
+	if _, ok := sequenceWindows(); activePolicy.MaxSequence > 0 && !ok {
+		limitations = append(limitations, exportLimitation{"character sequences", []string{"max-sequence"}})
+	}
+	if activePolicy.HasUserRules() && activePolicy.User() != (policy.User{}) {
+		limitations = append(limitations, exportLimitation{"the account", []string{"not-username", "not-email", "contains-username", "contains-email", "contains-display-name"}})
+	}
+	if len(activePolicy.Blocklist) > 0 {
+		limitations = append(limitations, exportLimitation{"blocklist", []string{"blocklist"}})
+	}
+	return limitations
+}
+
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	format := fs.String("format", "all", "Format to export: all, "+strings.Join(exportFormats, ", "))
+	selfCheck := fs.Bool("self-check", false, "Evaluate every export on generated passwords and compare it with the policy, results go to stderr")
+	iterations := fs.Int("n", 200, "Number of passwords per category for -self-check")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "export [flags]", "Writes the policy as an HTML pattern attribute, JSON Schema, OpenAPI schema or Python module. Exits with 1 when -self-check finds an export that disagrees with the policy, other than on the rules the exports leave out.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *iterations <= 0 {
+		usageError(fs, "-n must be a positive number")
+	}
+	formats := exportFormats
+	if *format != "all" {
+		if exportCheckers[*format] == nil {
+			usageError(fs, "unknown format %q", *format)
+		}
+		formats = []string{*format}
+	}
+	if limitations := exportLimitations(); len(limitations) > 0 {
+		var descriptions []string
+		for _, limitation := range limitations {
+			descriptions = append(descriptions, limitation.description)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: the exports leave out the rules for %s\n", strings.Join(descriptions, "; "))
+	}
+
+	for _, f := range formats {
+		if len(formats) > 1 {
+			fmt.Printf("--- %s ---\n", f)
+		}
+		fmt.Print(exportPolicy(f))
+	}
+	if *selfCheck && !selfCheckExport(formats, *iterations) {
+		os.Exit(exitThresholdExceeded)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+const ambiguousChars = "0O1lI|o"
+const generateLength = 16
+
+func runGenerate(args []string) {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	count := fs.Int("n", 1, "Number of passwords to generate")
+	length := fs.Int("length", generateLength, "Length of the passwords, the default moves into the lengths the policy allows")
+	maxLength := fs.Int("max-length", 0, "Pick a random length between -length and this for each password")
+	excludeAmbiguous := fs.Bool("exclude-ambiguous", false, "Leave out characters that are easy to confuse ("+ambiguousChars+")")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "generate [flags]", "Prints passwords that comply with the policy, using crypto/rand. Every password is checked by the validator before it is printed.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *count <= 0 {
+		usageError(fs, "-n must be a positive number")
+	}
+	// Like serve, the default length gives way to the policy's bounds
+	if !setFlags(fs)["length"] {
+		*length = max(*length, activePolicy.MinLength)
+		if activePolicy.MaxLength > 0 {
+			*length = min(*length, activePolicy.MaxLength)
+		}
+	}
+	if *length < activePolicy.MinLength {
+		usageError(fs, "-length must be at least %d to satisfy the policy", activePolicy.MinLength)
+	}
+	if *maxLength == 0 {
+		*maxLength = *length
+	} else if *maxLength < *length {
+		usageError(fs, "-max-length can't be shorter than -length")
+	}
+	if activePolicy.MaxLength > 0 && *maxLength > activePolicy.MaxLength {
+		usageError(fs, "the policy allows at most %d characters", activePolicy.MaxLength)
+	}
+	exclude := ""
+	if *excludeAmbiguous {
+		exclude = ambiguousChars
+	}
+
+	for i := 0; i < *count; i++ {
+		// Only passwords the validator accepts are returned
+		options := policy.GenerateOptions{Length: *length + policy.CryptoRand.Intn(*maxLength-*length+1), ExcludeChars: exclude}
+		generated, err := activePolicy.GenerateCompliant(policy.CryptoRand, options)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't generate a password the policy accepts: %s\n", err)
+			os.Exit(exitThresholdExceeded)
+		}
+		fmt.Println(generated)
+	}
+}
@@ -2,4 +2,15 @@ module github.com/TotallyMonica/testRegex
 
 go 1.22
 
-require github.com/pbnjay/memory v0.0.0-20210728143218-7b4eea64cf58
+require (
+	github.com/dop251/goja v0.0.0-20241024094426-79f3a7efcdbd
+	github.com/pbnjay/memory v0.0.0-20210728143218-7b4eea64cf58
+	github.com/tetratelabs/wazero v1.8.2
+)
+
+require (
+	github.com/dlclark/regexp2 v1.11.4 // indirect
+	github.com/go-sourcemap/sourcemap v2.1.3+incompatible // indirect
+	github.com/google/pprof v0.0.0-20230207041349-798e818bf904 // indirect
+	golang.org/x/text v0.3.8 // indirect
+)
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const categoryGolden = "golden"
+
+type goldenCase struct {
+	Password *string       `json:"password"`
+	Expected goldenVerdict `json:"expected"`
+	Reason   string        `json:"reason"`
+}
+
+// goldenVerdict accepts booleans as well as strings like "accept" or "reject"
+type goldenVerdict struct {
+	set   bool
+	value bool
+}
+
+func (v *goldenVerdict) UnmarshalJSON(data []byte) error {
+	var b bool
+	if err := json.Unmarshal(data, &b); err == nil {
+		v.set, v.value = true, b
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("expected must be a boolean or a string, got %s", data)
+	}
+	value, err := parseExpected(s)
+	if err != nil {
+		return err
+	}
+	v.set, v.value = true, value
+	return nil
+}
+
+func parseExpected(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "true", "accept", "accepted", "pass", "valid", "1":
+		return true, nil
+	case "false", "reject", "rejected", "fail", "invalid", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("unknown expected verdict %q", s)
+}
+
+func goldenFormat(path string, format string) string {
+	if format != "" {
+		return strings.ToLower(format)
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson":
+		return "jsonl"
+	}
+	return "csv"
+}
+
+// readGolden runs every labeled password of a golden file through the policy and
+// passes the outcome to handle
+func readGolden(path string, format string, handle func(result testResults)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	switch goldenFormat(path, format) {
+	case "csv":
+		return readGoldenCSV(path, file, handle)
+	case "jsonl":
+		return readGoldenJSONL(path, file, handle)
+	}
+	return fmt.Errorf("unknown golden file format %q, expected csv or jsonl", format)
+}
+
+func goldenResult(password string, expected bool, reason string) testResults {
+	return testResults{
+		expectedResult: expected,
+		actualResult:   activePolicy.Accepts(password),
+		testedPassword: password,
+		category:       categoryGolden,
+		reason:         reason,
+	}
+}
+
+// Quoted CSV fields may hold commas, quotes and newlines, so passwords are taken as-is from their column
+func readGoldenCSV(path string, file io.Reader, handle func(result testResults)) error {
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: error while reading header: %w", path, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"password", "expected"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%s: missing %s column", path, name)
+		}
+	}
+	reasonColumn, hasReason := columns["reason"]
+
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		row, _ := reader.FieldPos(0)
+
+		expected, err := parseExpected(line[columns["expected"]])
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, row, err)
+		}
+		reason := ""
+		if hasReason {
+			reason = line[reasonColumn]
+		}
+		handle(goldenResult(line[columns["password"]], expected, reason))
+	}
+}
+
+func readGoldenJSONL(path string, file io.Reader, handle func(result testResults)) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	row := 0
+	for scanner.Scan() {
+		row += 1
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var c goldenCase
+		if err := json.Unmarshal([]byte(line), &c); err != nil {
+			return fmt.Errorf("%s:%d: %w", path, row, err)
+		}
+		if c.Password == nil {
+			return fmt.Errorf("%s:%d: missing password", path, row)
+		}
+		if !c.Expected.set {
+			return fmt.Errorf("%s:%d: missing expected verdict", path, row)
+		}
+		handle(goldenResult(*c.Password, c.Expected.value, c.Reason))
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
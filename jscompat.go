@@ -0,0 +1,341 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/TotallyMonica/testRegex/policy"
+	"github.com/dop251/goja"
+)
+
+const categoryProbe = "probe"
+
+// probeEmoji is one code point but two UTF-16 code units
+const probeEmoji = "\U0001F600"
+
+// probeBase is a compliant password of length characters the probes are built on,
+// the same one every run. When the policy can't be met any rule it breaks is broken
+// for Go and JavaScript alike.
+func probeBase(length int) []rune {
+	base, err := activePolicy.GenerateCompliant(rand.New(rand.NewSource(1)), policy.GenerateOptions{Length: length})
+	if err != nil {
+		base = strings.Repeat("Qz7!mPx2", length/8+1)[:length]
+	}
+	return []rune(base)
+}
+
+// astralProbe replaces n characters of base with probeEmoji, at the last position
+// where the policy gives the probe the verdict want asks for
+func astralProbe(base []rune, n int, want func(password string) bool) string {
+	probe := ""
+	for i := len(base) - n; i >= 0; i-- {
+		probe = string(base[:i]) + probeEmoji + string(base[i+n:])
+		if want(probe) {
+			break
+		}
+	}
+	return probe
+}
+
+// onlyTooShort reports whether the length is all the policy has against a password
+func onlyTooShort(password string) bool {
+	failures := activePolicy.Validate(password).Failures
+	return len(failures) == 1 && failures[0].Rule == "min-length"
+}
+
+// replaceChars maps the characters of base that match to with, or appends with(0)
+// when none match
+func replaceChars(base []rune, match func(c rune) bool, with func(c rune) rune, all bool) string {
+	replaced := append([]rune(nil), base...)
+	found := false
+	for i, c := range replaced {
+		if match(c) && (all || !found) {
+			replaced[i] = with(c)
+			found = true
+		}
+	}
+	if !found {
+		replaced = append(replaced, with(0))
+	}
+	return string(replaced)
+}
+
+func isASCIIDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isASCIIUpper(c rune) bool {
+	return c >= 'A' && c <= 'Z'
+}
+
+// jsProbes are passwords the generators don't produce but where RE2 and
+// ECMAScript tend to disagree: astral characters count as two without the u flag,
+// and JavaScript has its own idea of line terminators and Unicode classes. They're
+// built on a compliant password of the active policy, so only the character under
+// test decides.
+func jsProbes() []string {
+	length := max(activePolicy.MinLength, 2)
+	if activePolicy.MaxLength > 0 {
+		length = min(length, activePolicy.MaxLength)
+	}
+	base := probeBase(length)
+	middle := len(base) / 2
+	probes := []string{
+		string(base),
+		string(base) + "\n",
+		"\n" + string(base),
+		string(base) + "\u2028",
+		string(base) + "\u00a0",
+		string(base) + "\x00",
+		string(base) + probeEmoji,
+		astralProbe(base, 1, activePolicy.Accepts),
+		string(base[:middle]) + "\u00df" + string(base[middle:]),
+		// Arabic-Indic and mathematical digits are \p{Nd} but never \d
+		replaceChars(base, isASCIIDigit, func(c rune) rune { return 0x0660 + max(c-'0', 2) }, true),
+		replaceChars(base, isASCIIDigit, func(c rune) rune { return 0x1D7D8 + max(c-'0', 2) }, true),
+		replaceChars(base, isASCIIUpper, func(c rune) rune { return '\u00c1' }, false),
+		// The Kelvin sign case folds to k with the u flag only
+		replaceChars(base, unicode.IsUpper, func(c rune) rune { return '\u212a' }, false),
+		// Fullwidth forms of ASCII punctuation
+		replaceChars(base, func(c rune) bool { return c > ' ' && c <= '~' && !unicode.IsLetter(c) && !unicode.IsDigit(c) }, func(c rune) rune { return 0xff01 + max(c-'!', 0) }, false),
+	}
+	// One character short, but long enough in UTF-16 code units
+	if activePolicy.MinLength >= 2 && len(base) >= 2 {
+		probes = append(probes, astralProbe(base, 2, onlyTooShort))
+	}
+	// As long as allowed, but too long in UTF-16 code units
+	if activePolicy.MaxLength > 0 {
+		probes = append(probes, astralProbe(probeBase(activePolicy.MaxLength), 1, activePolicy.Accepts))
+	}
+
+	seen := make(map[string]bool)
+	unique := probes[:0]
+	for _, probe := range probes {
+		if !seen[probe] {
+			seen[probe] = true
+			unique = append(unique, probe)
+		}
+	}
+	return unique
+}
+
+// jsValidatorSource mirrors policy.Accepts with RegExp objects. Rules that don't
+// use a regex are left to Go through otherRules.
+const jsValidatorSource = `
+function makeValidator(classes, valid, flags, minLength, maxLength, minClasses, otherRules) {
+	var compiled = [];
+	for (var i = 0; i < classes.length; i++) {
+		compiled.push({re: new RegExp(classes[i].pattern, flags + "g"), min: classes[i].min, credit: classes[i].credit});
+	}
+	var whole = new RegExp(valid, flags);
+	return function (password) {
+		if (password === "") {
+			return false;
+		}
+		var used = 0, credits = 0;
+		for (var i = 0; i < compiled.length; i++) {
+			var matches = password.match(compiled[i].re);
+			var count = matches ? matches.length : 0;
+			if (count < compiled[i].min) {
+				return false;
+			}
+			if (count > 0) {
+				used++;
+			}
+			credits += Math.min(count, compiled[i].credit);
+		}
+		if (used < minClasses || !whole.test(password) || !otherRules(password)) {
+			return false;
+		}
+		var length = Array.from(password).length;
+		return length + credits >= minLength && (maxLength === 0 || length <= maxLength);
+	};
+}
+`
+
+// jsOtherRules are the rules of the policy that don't depend on a regex dialect
+var jsOtherRules = map[string]bool{
+	"max-repeat":        true,
+	"max-sequence":      true,
+	"max-keyboard-walk": true,
+	"not-username":      true,
+	"not-email":         true,
+	"blocklist":         true,
+
+	"contains-username":     true,
+	"contains-email":        true,
+	"contains-display-name": true,
+}
+
+type jsDisagreement struct {
+	key      string
+	count    int
+	examples []string
+}
+
+// newJSValidator compiles the patterns of the active policy with new RegExp. The
+// error names the first pattern JavaScript refuses.
+func newJSValidator(flags string) (func(password string) bool, error) {
+	vm := goja.New()
+	_, err := vm.RunString(jsValidatorSource)
+	if err != nil {
+		return nil, err
+	}
+	makeValidator, _ := goja.AssertFunction(vm.Get("makeValidator"))
+
+	// Compile every pattern on its own first, so errors name the pattern
+	regExp := vm.Get("RegExp")
+	patterns := [][2]string{{"whole", activePolicy.ValidPattern()}}
+	for _, class := range activePolicy.Classes {
+		patterns = append(patterns, [2]string{class.Name, class.Pattern})
+	}
+	for _, pattern := range patterns {
+		_, err := vm.New(regExp, vm.ToValue(pattern[1]), vm.ToValue(flags))
+		if err != nil {
+			return nil, fmt.Errorf("%s /%s/%s: %w", pattern[0], pattern[1], flags, err)
+		}
+	}
+
+	classes := make([]map[string]any, len(activePolicy.Classes))
+	for i, class := range activePolicy.Classes {
+		classes[i] = map[string]any{"pattern": class.Pattern, "min": class.Min, "credit": class.Credit}
+	}
+	// Lengths past what the pattern can express are checked apart, like Accepts does
+	minLength, maxLength := 0, 0
+	credited := false
+	for _, class := range activePolicy.Classes {
+		credited = credited || class.Credit > 0
+	}
+	if activePolicy.MinLength > 1000 || activePolicy.MaxLength > 1000 || credited {
+		minLength, maxLength = activePolicy.MinLength, activePolicy.MaxLength
+	}
+	otherRules := func(password string) bool {
+		for _, failure := range activePolicy.Validate(password).Failures {
+			if jsOtherRules[failure.Rule] {
+				return false
+			}
+		}
+		return true
+	}
+	value, err := makeValidator(goja.Undefined(), vm.ToValue(classes), vm.ToValue(activePolicy.ValidPattern()), vm.ToValue(flags), vm.ToValue(minLength), vm.ToValue(maxLength), vm.ToValue(activePolicy.MinClasses), vm.ToValue(otherRules))
+	if err != nil {
+		return nil, err
+	}
+	validate, _ := goja.AssertFunction(value)
+
+	return func(password string) bool {
+		result, err := validate(goja.Undefined(), vm.ToValue(password))
+		if err != nil {
+			fatalIO("Error while running the JavaScript validator: ", err)
+		}
+		return result.ToBoolean()
+	}, nil
+}
+
+func jsVerdict(accepted bool) string {
+	if accepted {
+		return "accepted"
+	}
+	return "rejected"
+}
+
+func runJSCompat(args []string) {
+	fs := flag.NewFlagSet("js-compat", flag.ExitOnError)
+	iterations := fs.Int("n", 1000, "Number of passwords to generate for each category")
+	fs.Int64Var(&testSeed, "seed", 0, "Seed for the generated passwords")
+	flags := fs.String("flags", "", "Flags for new RegExp, like u")
+	examples := fs.Int("examples", 3, "Example passwords to show per disagreement")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "js-compat [flags]", "Compiles the policy patterns with new RegExp in an embedded JavaScript engine and reports every generated or probe password where Go and JavaScript disagree. Exits with 1 on any disagreement.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *iterations <= 0 || *examples < 0 {
+		usageError(fs, "-n must be positive and -examples can't be negative")
+	}
+	for _, flag := range *flags {
+		if flag != 'i' && flag != 'm' && flag != 's' && flag != 'u' && flag != 'y' {
+			usageError(fs, "unsupported RegExp flag %q", flag)
+		}
+	}
+
+	fmt.Printf("Patterns of policy %s:\n", activePolicy.Name)
+	for _, class := range activePolicy.Classes {
+		fmt.Printf("  %-14s /%s/%s\n", class.Name, class.Pattern, *flags)
+	}
+	fmt.Printf("  %-14s /%s/%s\n", "whole", activePolicy.ValidPattern(), *flags)
+	fmt.Println()
+	for _, class := range activePolicy.Classes {
+		if strings.Contains(class.Pattern, `\p`) && strings.Contains(*flags, "u") {
+			fmt.Fprintln(os.Stderr, "Warning: the embedded engine doesn't implement \\p{...} with the u flag, browsers may agree with Go where it doesn't")
+			break
+		}
+	}
+
+	jsAccepts, err := newJSValidator(*flags)
+	if err != nil {
+		fmt.Printf("JavaScript can't compile the policy: %s\n", err)
+		os.Exit(exitThresholdExceeded)
+	}
+
+	disagreements := make(map[string]*jsDisagreement)
+	checked := 0
+	check := func(category string, password string, goAccepted bool) {
+		checked += 1
+		jsAccepted := jsAccepts(password)
+		if goAccepted == jsAccepted {
+			return
+		}
+		key := fmt.Sprintf("%s: Go %s, JavaScript %s", category, jsVerdict(goAccepted), jsVerdict(jsAccepted))
+		if disagreements[key] == nil {
+			disagreements[key] = &jsDisagreement{key: key}
+		}
+		disagreements[key].count += 1
+		if len(disagreements[key].examples) < *examples {
+			disagreements[key].examples = append(disagreements[key].examples, password)
+		}
+	}
+
+	for _, probe := range jsProbes() {
+		check(categoryProbe, probe, activePolicy.Accepts(probe))
+	}
+	options := []policy.Option{policy.WithIterations(*iterations)}
+	if testSeed != 0 {
+		options = append(options, policy.WithSeed(testSeed))
+	}
+	runner := policy.NewRunner(activePolicy, options...)
+	runner.Run(func(result policy.Result) error {
+		check(result.Category, result.Password, result.Actual)
+		return nil
+	})
+
+	sorted := make([]*jsDisagreement, 0, len(disagreements))
+	total := 0
+	for _, disagreement := range disagreements {
+		sorted = append(sorted, disagreement)
+		total += disagreement.count
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+
+	fmt.Printf("Checked %d passwords (seed %d), %d verdicts differ\n", checked, runner.Seed(), total)
+	for _, disagreement := range sorted {
+		fmt.Printf("  %s (%d)\n", disagreement.key, disagreement.count)
+		for _, example := range disagreement.examples {
+			fmt.Printf("    %q\n", example)
+		}
+	}
+	if total > 0 {
+		os.Exit(exitThresholdExceeded)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+func runLint(args []string) {
+	fs := flag.NewFlagSet("lint", flag.ExitOnError)
+	jsonOutput := fs.Bool("json", false, "Print one JSON object per issue instead of text")
+	notes := fs.Bool("notes", true, "Also report notes, which never fail the lint")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "lint [flags]", "Checks the policy for contradicting, unreachable and unsatisfiable rules, and for generator characters that drifted from the patterns. Exits with 1 on any error or warning.")
+	}
+	fs.Parse(args)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+
+	// Contradictions New would reject are reported instead of failing the flags
+	definition := readPolicyFlags(fs)
+	issues := policy.CheckDefinition(definition)
+	if len(issues) == 0 {
+		p, err := policy.New(definition)
+		if err != nil {
+			issues = append(issues, policy.Issue{Level: policy.LintError, Check: "pattern", Message: err.Error()})
+		} else {
+			issues = p.ForUser(policyUser).Lint()
+		}
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	failed := false
+	counts := make(map[string]int)
+	for _, issue := range issues {
+		counts[issue.Level] += 1
+		if issue.Level != policy.LintNote {
+			failed = true
+		} else if !*notes {
+			continue
+		}
+		if *jsonOutput {
+			if err := encoder.Encode(issue); err != nil {
+				fatalIO("Error while writing output: ", err)
+			}
+			continue
+		}
+		fmt.Printf("%s: %s: %s\n", issue.Level, issue.Check, issue.Message)
+	}
+
+	if !*jsonOutput {
+		fmt.Printf("Policy %s: %d errors, %d warnings, %d notes\n", definition.Name, counts[policy.LintError], counts[policy.LintWarning], counts[policy.LintNote])
+	}
+	if failed {
+		os.Exit(exitThresholdExceeded)
+	}
+}
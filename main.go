@@ -2,24 +2,36 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
 	"log"
-	"math/rand"
 	"os"
-	"regexp"
-	"strconv"
 	"strings"
 	"time"
+
+	"github.com/TotallyMonica/testRegex/policy"
 )
 
 type testResults struct {
 	expectedResult bool
 	actualResult   bool
 	testedPassword string
+	category       string
+	reason         string
 }
 
+const (
+	categoryShouldPass             = "should-pass"
+	categoryShouldFailLength       = "should-fail-length"
+	categoryShouldFailUpper        = "should-fail-upper"
+	categoryShouldFailLower        = "should-fail-lower"
+	categoryShouldFailNumber       = "should-fail-number"
+	categoryShouldFailSpecialChars = "should-fail-special-chars"
+	categoryShouldFailIllegalChars = "should-fail-illegal-chars"
+)
+
 var runShouldPass bool
 var runShouldFailLength bool
 var runShouldFailUpper bool
@@ -30,718 +42,342 @@ var runShouldFailIllegalChars bool
 var doTests bool
 var doEvals bool
 var exitOnFail bool
+var failuresOnly bool
+var goldenFile string
+var listFailures bool
+var baselinePath string
+var knownBaseline *knownFailures
+var maxFalseAcceptRate float64
+var maxFalseRejectRate float64
+var categoryLimits = make(categoryThresholds)
+var goldenFileFormat string
 var testsToRun int
+var testSeed int64
 var showProgress bool
-
-var validPasswordRegex *regexp.Regexp
-var passwordUpperLettersRegex *regexp.Regexp
-var passwordLowerLettersRegex *regexp.Regexp
-var passwordNumbersRegex *regexp.Regexp
-var passwordLegalSpecialRegex *regexp.Regexp
-var legalChars = []string{"-", "_", ".", "!", "$", "|", "@", "%", "^", "&", "*"}
-var illegalChars = []string{"+", "=", "(", ")", "#", "~", "}", "{", "[", "]", "\\", "<", ">", "/", "?", " ", "\"", "'", "`", ","}
+var showDashboard bool
+var progressOutput io.Writer = os.Stdout
+var runAllTests bool
+var categoriesToRun []string
+
+// activePolicy is the policy every command validates against
+var activePolicy *policy.Policy
+
+// targetValidator replaces the policy's own validation in runs when -wasm is given
+var targetValidator *wasmValidator
+var targetPath string
+var keycloakPolicy string
+var pwqualityPath string
+var loginDefsPath string
+var presetName string
+var policyUser policy.User
+var sampleUser string
+var maxRepeat int
+var maxSequence int
+var maxKeyboardWalk int
 
 const updateFrequency = 1000 * 100 // Change right number to change decimal precision, 1 means ever 0.01% increase
 
-func runRegexp(passwd string) bool {
-	upperCharCheck := passwordUpperLettersRegex.FindString(passwd) != ""
-	lowerCharCheck := passwordLowerLettersRegex.FindString(passwd) != ""
-	numberCheck := passwordNumbersRegex.FindString(passwd) != ""
-	legalSpecialCharCheck := passwordLegalSpecialRegex.FindString(passwd) != ""
-	validPasswordCheck := validPasswordRegex.FindString(passwd) != ""
-	emptyStringCheck := passwd == ""
+// errStopRun stops the runner once exit-on-fail has seen a new failure
+var errStopRun = errors.New("stopped on first failure")
 
-	return upperCharCheck && lowerCharCheck && numberCheck && legalSpecialCharCheck && validPasswordCheck && !emptyStringCheck
+func loadPolicy() {
+	activePolicy = policy.Default()
 }
 
-func printUpdate(prefix string, current int, max int, elapsed time.Duration) {
-	fmt.Printf("--- %s --- Ran %d out of %d tests (%.2f%%) in ", prefix, current, max, float32(current)/float32(max)*100)
+func formatElapsed(elapsed time.Duration) string {
 	if elapsed.Nanoseconds() < 1000 {
-		fmt.Printf("%d nanoseconds\n", elapsed.Nanoseconds())
+		return fmt.Sprintf("%d nanoseconds", elapsed.Nanoseconds())
 	} else if elapsed.Microseconds() < 1000 {
-		fmt.Printf("%d microseconds\n", elapsed.Microseconds())
+		return fmt.Sprintf("%d microseconds", elapsed.Microseconds())
 	} else if elapsed.Milliseconds() < 1000 {
-		fmt.Printf("%d milliseconds\n", elapsed.Milliseconds())
+		return fmt.Sprintf("%d milliseconds", elapsed.Milliseconds())
 	} else if elapsed.Seconds() < 60 {
-		fmt.Printf("%.3g seconds\n", elapsed.Seconds())
+		return fmt.Sprintf("%.3g seconds", elapsed.Seconds())
 	} else if elapsed.Minutes() < 60 {
-		fmt.Printf("%d minutes, %d seconds\n", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
-	} else {
-		fmt.Printf("%d hours, %d minutes, %d seconds\n", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60)
+		return fmt.Sprintf("%d minutes, %d seconds", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
 	}
+	return fmt.Sprintf("%d hours, %d minutes, %d seconds", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60)
 }
 
-func shouldFailIllegalChars(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on illegal characters\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25)
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(25)
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(25)
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
-
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(25)
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
-		}
-
-		// Generate random number of _illegal_ special characters
-		illegalSpecialCharsCount := rand.Intn(25) + 1
-		illegalSpecialChars := make([]string, illegalSpecialCharsCount)
-		for i := 0; i < illegalSpecialCharsCount; i++ {
-			illegalSpecialChars = append(illegalSpecialChars, illegalChars[rand.Intn(len(illegalChars))])
-		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(illegalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
+func printUpdate(prefix string, current int, max int, elapsed time.Duration) {
+	fmt.Fprintf(progressOutput, "--- %s --- Ran %d out of %d tests (%.2f%%) in %s\n", prefix, current, max, float32(current)/float32(max)*100, formatElapsed(elapsed))
+}
 
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
+// The original flags select categories of the default policy
+type testCategory struct {
+	name     string
+	selected *bool
+}
 
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
-		}
+var testCategories = []testCategory{
+	{name: categoryShouldPass, selected: &runShouldPass},
+	{name: categoryShouldFailSpecialChars, selected: &runShouldFailSpecialChars},
+	{name: categoryShouldFailIllegalChars, selected: &runShouldFailIllegalChars},
+	{name: categoryShouldFailNumber, selected: &runShouldFailNumber},
+	{name: categoryShouldFailUpper, selected: &runShouldFailUpper},
+	{name: categoryShouldFailLower, selected: &runShouldFailLower},
+	{name: categoryShouldFailLength, selected: &runShouldFailLength},
+}
 
-		testCount += 1
-		t := time.Now()
-		elapsed := t.Sub(start)
-		if showProgress && testCount%updateFrequency == 0 {
-			printUpdate("SHOULD FAIL ILLEGAL CHARACTERS", testCount, testsToRun, elapsed)
+func selectLegacyCategories() {
+	if runAllTests {
+		return
+	}
+	for _, category := range testCategories {
+		if *category.selected {
+			categoriesToRun = append(categoriesToRun, category.name)
 		}
 	}
-
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL ILLEGAL CHARACTERS", testCount, testsToRun, elapsed)
 }
 
-func shouldPass(c chan testResults) {
-	fmt.Printf("Running %d tests that should pass successfully\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
-
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
-		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
-
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
-		pass := runRegexp(generatedPassword)
-
-		results := testResults{
-			expectedResult: true,
-			actualResult:   pass,
-			testedPassword: generatedPassword,
-		}
-
-		c <- results
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
-			t := time.Now()
-			elapsed := t.Sub(start)
-			printUpdate("SHOULD PASS", testCount, testsToRun, elapsed)
-		}
+// newRunner creates the runner for the selected categories, nil categories runs all of them
+func newRunner(progress func(policy.Progress)) *policy.Runner {
+	options := []policy.Option{policy.WithIterations(testsToRun), policy.WithProgress(updateFrequency, progress)}
+	if !runAllTests && categoriesToRun != nil {
+		options = append(options, policy.WithCategories(categoriesToRun...))
 	}
-
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD PASS", testCount, testsToRun, elapsed)
+	if testSeed != 0 {
+		options = append(options, policy.WithSeed(testSeed))
+	}
+	if targetValidator != nil {
+		options = append(options, policy.WithValidator(targetValidator.Accepts))
+	}
+	return policy.NewRunner(activePolicy, options...)
 }
 
-func shouldFailSpecialChars(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on missing special characters\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
-
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, "")
-		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
-
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
+func categoryTitle(name string) string {
+	return strings.ToUpper(strings.ReplaceAll(name, "-", " "))
+}
 
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
-		}
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
-			t := time.Now()
-			elapsed := t.Sub(start)
-			printUpdate("SHOULD FAIL SPECIAL CHARS", testCount, testsToRun, elapsed)
-		}
+func printSettings() {
+	fmt.Printf("Do tests:                %t\n", doTests)
+	fmt.Printf("Do evals:                %t\n", doEvals)
+	fmt.Printf("Golden file:             %s\n", goldenFile)
+	fmt.Printf("Baseline:                %s\n", baselinePath)
+	fmt.Printf("Policy:                  %s\n", activePolicy.Name)
+	fmt.Printf("Run all tests:           %t\n", doTests && (runAllTests || categoriesToRun == nil))
+	for _, category := range newRunner(nil).Categories() {
+		fmt.Printf("Test %-20s%t\n", category.Name+":", doTests)
 	}
-
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL SPECIAL CHARS", testCount, testsToRun, elapsed)
+	fmt.Printf("Show progress:           %t\n", showProgress)
+	fmt.Printf("Show dashboard:          %t\n", showDashboard)
+	fmt.Printf("Exit on fail:            %t\n", exitOnFail)
+	fmt.Printf("Failures only:           %t\n", failuresOnly)
+	fmt.Printf("Test repeat count:       %d\n", testsToRun)
+	fmt.Printf("Seed:                    %d\n", testSeed)
 }
 
-func shouldFailNumber(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on missing numbers\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, "")
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
-
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
-		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
-
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
-
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
-		}
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
-			t := time.Now()
-			elapsed := t.Sub(start)
-			printUpdate("SHOULD FAIL NUMBER", testCount, testsToRun, elapsed)
-		}
+func loadKnownBaseline() {
+	if baselinePath == "" {
+		return
+	}
+	var err error
+	knownBaseline, err = loadBaseline(baselinePath)
+	if err != nil {
+		fatalIO("Error while reading baseline: ", err)
 	}
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL NUMBER", testCount, testsToRun, elapsed)
 }
 
-func shouldFailLower(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on missing lowercase letters\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, "")
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
+func runTests(start time.Time) {
+	counts := make(map[string]*categoryCounts)
 
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
+	// Create CSV for writing results
+	file, err := os.Create(resultsFile)
+	if err != nil {
+		fatalIO(err)
+	}
+	defer func(file *os.File) {
+		err := file.Close()
+		if err != nil {
+			fatalIOf("Error while closing file %s\n%s\n", file.Name(), err)
 		}
+	}(file)
 
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
+	// Create writer to handle the writing
+	writer := csv.NewWriter(file)
 
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
+	// Add the headers
+	err = writer.Write(resultsHeader)
+	if err != nil {
+		fatalIOf("Error while writing headers to file %s\n%s\n", file.Name(), err)
+	}
 
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
+	runner := newRunner(func(progress policy.Progress) {
+		if showProgress || progress.Done == progress.Total {
+			printUpdate(categoryTitle(progress.Category), progress.Done, progress.Total, progress.Elapsed)
 		}
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
-			t := time.Now()
-			elapsed := t.Sub(start)
-			printUpdate("SHOULD FAIL LOWER", testCount, testsToRun, elapsed)
+	})
+	categories := runner.Categories()
+	tests := len(categories)
+	var names []string
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+
+	// The dashboard replaces the line output, unless stdout isn't a terminal
+	var dash *dashboard
+	if showDashboard {
+		if isTerminal(os.Stdout) {
+			dash = newDashboard(names, testsToRun)
+			progressOutput = io.Discard
+			dash.start()
+		} else {
+			showProgress = true
 		}
 	}
 
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL LOWER", testCount, testsToRun, elapsed)
-}
+	fmt.Fprintf(progressOutput, "Seed: %d\n", runner.Seed())
+	for _, category := range categories {
+		fmt.Fprintf(progressOutput, "Running %d tests that %s\n", testsToRun, category.Description)
+	}
 
-func shouldFailUpper(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on missing uppercase letters\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
+	// Write the results of each test to the CSV
+	i := 0
+	err = runner.Run(func(r policy.Result) error {
+		if targetValidator != nil && targetValidator.Err() != nil {
+			return targetValidator.Err()
 		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
+		result := testResults{expectedResult: r.Expected, actualResult: r.Actual, testedPassword: r.Password, category: r.Category}
+		if dash != nil {
+			dash.record(result)
 		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, "")
+		if counts[result.category] == nil {
+			counts[result.category] = &categoryCounts{}
 		}
+		counts[result.category].add(result.expectedResult, result.actualResult)
 
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
+		// Passing rows are only tallied when running in failures only mode
+		if !failuresOnly || result.expectedResult != result.actualResult {
+			row := []string{result.testedPassword, fmt.Sprintf("%t", result.expectedResult), fmt.Sprintf("%t", result.actualResult), result.category}
+			err := writer.Write(row)
+			if err != nil {
+				return err
+			}
+			counts[result.category].rows += 1
 		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
-
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
-
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
+		if i%updateFrequency == 0 {
+			writer.Flush()
+			if showProgress {
+				t := time.Now()
+				elapsed := t.Sub(start)
+				printUpdate("OVERALL", i, testsToRun*tests, elapsed)
+			}
 		}
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
+		i += 1
+		if exitOnFail && result.expectedResult != result.actualResult && (knownBaseline == nil || !knownBaseline.known(result)) {
+			if dash != nil {
+				dash.stop()
+			}
 			t := time.Now()
 			elapsed := t.Sub(start)
-			printUpdate("SHOULD FAIL UPPER", testCount, testsToRun, elapsed)
+			printUpdate("OVERALL", i, testsToRun*tests, elapsed)
+			fmt.Printf("Password %s failed (Expected %t, got %t)\n", result.testedPassword, result.expectedResult, result.actualResult)
+			return errStopRun
 		}
-	}
-	t := time.Now()
-	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL UPPER", testCount, testsToRun, elapsed)
-}
-
-func shouldFailLength(c chan testResults) {
-	fmt.Printf("Running %d tests that should fail on too short of a password\n", testsToRun)
-	start := time.Now()
-	testCount := 0
-	// Run 50 iterations of the test
-	for i := 0; i < testsToRun; i++ {
-		// Generate random number of numbers
-		numberCount := rand.Intn(25) + 2
-		nums := make([]string, numberCount)
-		for i := 0; i < numberCount; i++ {
-			nums = append(nums, strconv.Itoa(rand.Intn(10)))
-		}
-
-		// Generate random number of lower characters
-		lowerCharCount := rand.Intn(23) + 2
-		lowerChars := make([]string, lowerCharCount)
-		for i := 0; i < lowerCharCount; i++ {
-			lowerChars = append(lowerChars, string(rune(rand.Intn(26)+97)))
-		}
-
-		// Generate random number of lower characters
-		upperCharsCount := rand.Intn(23) + 2
-		upperChars := make([]string, upperCharsCount)
-		for i := 0; i < upperCharsCount; i++ {
-			upperChars = append(upperChars, string(rune(rand.Intn(26)+65)))
-		}
-
-		// Generate random number of _legal_ special characters
-		legalSpecialCharsCount := rand.Intn(23) + 2
-		legalSpecialChars := make([]string, legalSpecialCharsCount)
-		for i := 0; i < legalSpecialCharsCount; i++ {
-			legalSpecialChars = append(legalSpecialChars, legalChars[rand.Intn(len(legalChars))])
+		return nil
+	})
+	if errors.Is(err, errStopRun) {
+		writer.Flush()
+		err = writeCounts(countsFile, counts)
+		if err != nil {
+			log.Printf("Error while writing counts to file %s\n%s\n", countsFile, err)
 		}
-
-		generatedPassword := strings.Join(legalSpecialChars[:], "") + strings.Join(lowerChars[:], "") + strings.Join(upperChars[:], "") + strings.Join(nums[:], "")
-
-		shuff := []rune(generatedPassword)
-		rand.Shuffle(len(shuff), func(i, j int) {
-			shuff[i], shuff[j] = shuff[j], shuff[i]
-		})
-		generatedPassword = string(shuff)
-
-		generatedPassword = generatedPassword[:rand.Intn(7)+1]
-
-		c <- testResults{
-			expectedResult: false,
-			actualResult:   runRegexp(generatedPassword),
-			testedPassword: generatedPassword,
+		os.Exit(exitThresholdExceeded)
+	} else if err != nil && targetValidator != nil && targetValidator.Err() != nil {
+		if dash != nil {
+			dash.stop()
 		}
-		testCount += 1
-		if showProgress && testCount%updateFrequency == 0 {
-			t := time.Now()
-			elapsed := t.Sub(start)
-			printUpdate("SHOULD FAIL LENGTH", testCount, testsToRun, elapsed)
+		fatalIOf("Error while running %s\n%s\n", targetPath, err)
+	} else if err != nil {
+		if dash != nil {
+			dash.stop()
 		}
+		t := time.Now()
+		elapsed := t.Sub(start)
+		printUpdate("OVERALL", i, testsToRun*tests, elapsed)
+		fatalIOf("Issue while writing to file %s\n%s\n", file.Name(), err)
+	}
+	if dash != nil {
+		dash.stop()
+	}
+	writer.Flush()
+	err = writeCounts(countsFile, counts)
+	if err != nil {
+		fatalIOf("Error while writing counts to file %s\n%s\n", countsFile, err)
 	}
 	t := time.Now()
 	elapsed := t.Sub(start)
-	printUpdate("SHOULD FAIL LENGTH", testCount, testsToRun, elapsed)
+
+	fmt.Printf("Total time to run tests: %s\n", formatElapsed(elapsed))
 }
 
 func main() {
-	// Parse flags
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
+	// Without a command the original flags are used, so existing scripts keep working
 	flag.BoolVar(&doTests, "run-tests", false, "Run the tests. Omission takes precedence over -all and specifying individual tests")
 	flag.BoolVar(&doEvals, "run-evals", false, "Evaluate results.csv")
-	flag.BoolVar(&showProgress, "show-progress", false, "Print progress to stdout")
+	flag.BoolVar(&runShouldPass, "run-should-pass-test", false, "Test to make sure compliant passwords are accepted")
 	flag.BoolVar(&runShouldFailSpecialChars, "run-special-char-test", false, "Test to make sure special characters are required")
 	flag.BoolVar(&runShouldFailIllegalChars, "run-illegal-char-test", false, "Test to make sure illegal characters aren't allowed")
 	flag.BoolVar(&runShouldFailLength, "run-length-test", false, "Test to make sure passwords need to be sufficiently long enough")
 	flag.BoolVar(&runShouldFailLower, "run-lowercase-test", false, "Test to make sure lowercase letters are required")
 	flag.BoolVar(&runShouldFailUpper, "run-uppercase-test", false, "Test to make sure uppercase letters are required")
 	flag.BoolVar(&runShouldFailNumber, "run-numbers-test", false, "Test to make sure numbers are required")
-	flag.BoolVar(&exitOnFail, "exit-on-fail", false, "Exit immediately on fail")
 	flag.IntVar(&testsToRun, "run", 100, "Specify how many times a test should be run")
+	flag.BoolVar(&runAllTests, "run-all-tests", false, "Runs all tests. Takes precedence of running specific tests")
 	verbose := flag.Bool("verbose", false, "Show verbose output")
-	runAllTests := flag.Bool("run-all-tests", false, "Runs all tests. Takes precedence of running specific tests")
+	addRunFlags(flag.CommandLine)
+	addEvalFlags(flag.CommandLine)
+	addBaselineFlag(flag.CommandLine)
 
+	flag.Usage = func() {
+		printUsage(flag.CommandLine, "[flags]\n       "+programName+" COMMAND [flags]", "Run \""+programName+" help\" to list the commands. The flags below are kept for existing scripts.")
+	}
 	flag.Parse()
 
-	// Compile regexes
-	var err error
-	validPasswordRegex, err = regexp.Compile(`^([A-Z]|[a-z]|[0-9]|-|_|\.|!|\$|\||@|%|\^|&|\*){8,}$`)
-	if err != nil {
-		log.Fatal("Error while compiling regex\n", err)
+	// Options that would otherwise be ignored silently
+	set := setFlags(flag.CommandLine)
+	if flag.NArg() > 0 {
+		usageError(flag.CommandLine, "unexpected argument %q", flag.Arg(0))
 	}
-	passwordUpperLettersRegex, err = regexp.Compile(`[A-Z]`)
-	if err != nil {
-		log.Fatal("Error while compiling regex\n", err)
+	if !doTests {
+		for _, name := range []string{"run-all-tests", "run-should-pass-test", "run-special-char-test", "run-illegal-char-test", "run-length-test", "run-lowercase-test", "run-uppercase-test", "run-numbers-test", "run", "show-progress", "failures-only", "exit-on-fail", "dashboard"} {
+			if set[name] {
+				usageError(flag.CommandLine, "-%s has no effect without -run-tests", name)
+			}
+		}
 	}
-	passwordLowerLettersRegex, err = regexp.Compile(`[a-z]`)
-	if err != nil {
-		log.Fatal("Error while compiling regex\n", err)
+	selectLegacyCategories()
+	if doTests && !runAllTests && categoriesToRun == nil {
+		usageError(flag.CommandLine, "-run-tests needs -run-all-tests or at least one -run-*-test flag")
 	}
-	passwordNumbersRegex, err = regexp.Compile(`[0-9]`)
-	if err != nil {
-		log.Fatal("Error while compiling regex\n", err)
+	if testsToRun <= 0 {
+		usageError(flag.CommandLine, "-run must be a positive number")
 	}
-	passwordLegalSpecialRegex, err = regexp.Compile(`([-_.!$|@%^&*])`)
-	if err != nil {
-		log.Fatal("Error while compiling regex\n", err)
+	if !doEvals && goldenFile == "" {
+		for _, name := range []string{"list-failures", "max-false-accept-rate", "max-false-reject-rate", "category-threshold"} {
+			if set[name] {
+				usageError(flag.CommandLine, "-%s has no effect without -run-evals or -golden", name)
+			}
+		}
 	}
+	validateEvalFlags(flag.CommandLine, set)
 
+	loadPolicy()
 	if *verbose {
-		fmt.Printf("Do tests:                %t\n", doTests)
-		fmt.Printf("Do evals:                %t\n", doEvals)
-		fmt.Printf("Run all tests:           %t\n", doTests && *runAllTests)
-		fmt.Printf("Test special characters: %t\n", doTests && (runShouldFailSpecialChars || *runAllTests))
-		fmt.Printf("Test illegal characters: %t\n", doTests && (runShouldFailIllegalChars || *runAllTests))
-		fmt.Printf("Test uppercase letters:  %t\n", doTests && (runShouldFailUpper || *runAllTests))
-		fmt.Printf("Test lowercase letters:  %t\n", doTests && (runShouldFailLower || *runAllTests))
-		fmt.Printf("Test numbers:            %t\n", doTests && (runShouldFailNumber || *runAllTests))
-		fmt.Printf("Test length:             %t\n", doTests && (runShouldFailLength || *runAllTests))
-		fmt.Printf("Show progress:           %t\n", showProgress)
-		fmt.Printf("Exit on fail:            %t\n", exitOnFail)
-		fmt.Printf("Test repeat count:       %d\n", testsToRun)
+		printSettings()
 	}
+	loadKnownBaseline()
 
 	start := time.Now()
 	if doTests {
-		c := make(chan testResults)
-		tests := 0
-
-		// Create CSV for writing results
-		file, err := os.Create("results.csv")
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer func(file *os.File) {
-			err := file.Close()
-			if err != nil {
-				log.Fatalf("Error while closing file %s\n%s\n", file.Name(), err)
-			}
-		}(file)
-
-		// Create writer to handle the writing
-		writer := csv.NewWriter(file)
-
-		// Add the headers
-		err = writer.Write([]string{"password", "expected", "actual"})
-		if err != nil {
-			log.Fatalf("Error while writing headers to file %s\n%s\n", file.Name(), err)
-		}
-
-		// Start the various tests
-		if runShouldPass || *runAllTests {
-			go shouldPass(c)
-			tests += 1
-		}
-		if runShouldFailSpecialChars || *runAllTests {
-			go shouldFailSpecialChars(c)
-			tests += 1
-		}
-		if runShouldFailIllegalChars || *runAllTests {
-			go shouldFailIllegalChars(c)
-			tests += 1
-		}
-		if runShouldFailNumber || *runAllTests {
-			go shouldFailNumber(c)
-			tests += 1
-		}
-		if runShouldFailUpper || *runAllTests {
-			go shouldFailUpper(c)
-			tests += 1
-		}
-		if runShouldFailLower || *runAllTests {
-			go shouldFailLower(c)
-			tests += 1
-		}
-		if runShouldFailLength || *runAllTests {
-			go shouldFailLength(c)
-			tests += 1
-		}
-
-		// Write the results of each test to the CSV
-		for i := 0; i < testsToRun*tests; i++ {
-			result := <-c
-			row := []string{result.testedPassword, fmt.Sprintf("%t", result.expectedResult), fmt.Sprintf("%t", result.actualResult)}
-			err := writer.Write(row)
-			if err != nil {
-				t := time.Now()
-				elapsed := t.Sub(start)
-				printUpdate("OVERALL", i, testsToRun*tests, elapsed)
-				log.Panicf("Issue while writing to file %s\n%s\n", file.Name(), err)
-			}
-			if i%updateFrequency == 0 {
-				writer.Flush()
-				if showProgress {
-					t := time.Now()
-					elapsed := t.Sub(start)
-					printUpdate("OVERALL", i, testsToRun*tests, elapsed)
-				}
-			}
-			if exitOnFail && result.expectedResult != result.actualResult {
-				t := time.Now()
-				elapsed := t.Sub(start)
-				printUpdate("OVERALL", i, testsToRun*tests, elapsed)
-				fmt.Printf("Password %s failed (Expected %t, got %t)\n", result.testedPassword, result.expectedResult, result.actualResult)
-				os.Exit(1)
-			}
-		}
-		writer.Flush()
-		t := time.Now()
-		elapsed := t.Sub(start)
-
-		fmt.Printf("Total time to run tests: ")
-		if elapsed.Nanoseconds() < 1000 {
-			fmt.Printf("%d nanoseconds\n", elapsed.Nanoseconds())
-		} else if elapsed.Microseconds() < 1000 {
-			fmt.Printf("%d microseconds\n", elapsed.Microseconds())
-		} else if elapsed.Milliseconds() < 1000 {
-			fmt.Printf("%d milliseconds\n", elapsed.Milliseconds())
-		} else if elapsed.Seconds() < 60 {
-			fmt.Printf("%.3g seconds\n", elapsed.Seconds())
-		} else if elapsed.Minutes() < 60 {
-			fmt.Printf("%d minutes, %d seconds\n", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
-		} else {
-			fmt.Printf("%d hours, %d minutes, %d seconds\n", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60)
-		}
+		runTests(start)
 	}
-
-	if doEvals {
-		evalStart := time.Now()
-		// Create CSV Reader
-		file, err := os.Open("results.csv")
-		if err != nil {
-			log.Fatalf("Error while opening file %s\n", err)
-		}
-		defer func(file *os.File) {
-			err := file.Close()
-			if err != nil {
-				log.Fatalf("Error while closing file %s\n%s\n", file.Name(), err)
-			}
-		}(file)
-		reader := csv.NewReader(file)
-
-		// We want to ignore the header
-		line, err := reader.Read()
-		if err != nil {
-			log.Fatal("Error while parsing CSV: ", err)
-		}
-		line, err = reader.Read()
-		if err != nil && err != io.EOF {
-			log.Fatal("Error while parsing CSV: ", err)
-		}
-
-		// Get all the tests
-		failedTests := 0
-		totalOfTests := 0
-		for err != io.EOF {
-			password := strings.Join(line[:len(line)-2], ",")
-			expected := strings.ToLower(line[len(line)-2])
-			actual := strings.ToLower(line[len(line)-1])
-
-			// Report if a password had different results from what was expected
-			if expected != actual {
-				fmt.Printf("%s did not meet expectations (Expected result of %s, got %s)\n", password, expected, actual)
-				failedTests += 1
-			}
-			totalOfTests += 1
-			line, err = reader.Read()
-			if err != nil && err != io.EOF {
-				log.Fatal("Error while parsing CSV: ", err)
-			}
-		}
-		t := time.Now()
-		elapsed := t.Sub(evalStart)
-
-		fmt.Printf("Total number of tests ran: %d\n", totalOfTests)
-		fmt.Printf("Number of passing tests: %d (%.3g%%)\n", totalOfTests-failedTests, float32((totalOfTests-failedTests)/totalOfTests)*100)
-
-		fmt.Printf("Total time to evaluate test results: ")
-		if elapsed.Nanoseconds() < 1000 {
-			fmt.Printf("%d nanoseconds\n", elapsed.Nanoseconds())
-		} else if elapsed.Microseconds() < 1000 {
-			fmt.Printf("%d microseconds\n", elapsed.Microseconds())
-		} else if elapsed.Milliseconds() < 1000 {
-			fmt.Printf("%d milliseconds\n", elapsed.Milliseconds())
-		} else if elapsed.Seconds() < 60 {
-			fmt.Printf("%.3g seconds\n", elapsed.Seconds())
-		} else if elapsed.Minutes() < 60 {
-			fmt.Printf("%d minutes, %d seconds\n", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
-		} else {
-			fmt.Printf("%d hours, %d minutes, %d seconds\n", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60)
-		}
-
-		t = time.Now()
-		elapsed = t.Sub(start)
-		fmt.Printf("Overall time to evaluate test results: ")
-		if elapsed.Nanoseconds() < 1000 {
-			fmt.Printf("%d nanoseconds\n", elapsed.Nanoseconds())
-		} else if elapsed.Microseconds() < 1000 {
-			fmt.Printf("%d microseconds\n", elapsed.Microseconds())
-		} else if elapsed.Milliseconds() < 1000 {
-			fmt.Printf("%d milliseconds\n", elapsed.Milliseconds())
-		} else if elapsed.Seconds() < 60 {
-			fmt.Printf("%.3g seconds	\n", elapsed.Seconds())
-		} else if elapsed.Minutes() < 60 {
-			fmt.Printf("%d minutes, %d seconds\n", int(elapsed.Minutes()), int(elapsed.Seconds())%60)
-		} else {
-			fmt.Printf("%d hours, %d minutes, %d seconds\n", int(elapsed.Hours()), int(elapsed.Minutes())%60, int(elapsed.Seconds())%60)
-		}
-
-		os.Exit(failedTests)
+	if doEvals || goldenFile != "" {
+		runEvals(start)
 	}
 }
@@ -0,0 +1,129 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// minContextLength is the shortest attribute the containment rules look for,
+// shorter ones would reject too many passwords by chance
+const minContextLength = 3
+
+// SampleUsers are made up accounts for testing the rules that compare passwords
+// with the user, with the separators and accents real names have.
+func SampleUsers() []User {
+	return []User{
+		{Username: "jsmith", Email: "john.smith@example.com", DisplayName: "John Smith"},
+		{Username: "maria.garcia", Email: "mgarcia@example.org", DisplayName: "María García-López"},
+		{Username: "svc_backup", Email: "backup-alerts@example.net", DisplayName: "Backup Service, Ops"},
+		{Username: "oconnor", Email: "kate.oconnor@example.com", DisplayName: "Kate O'Connor"},
+	}
+}
+
+// LookupSampleUser finds a sample user by username.
+func LookupSampleUser(username string) (User, bool) {
+	for _, user := range SampleUsers() {
+		if user.Username == username {
+			return user, true
+		}
+	}
+	return User{}, false
+}
+
+// HasUserRules reports whether any rule compares the password with the user.
+func (p *Policy) HasUserRules() bool {
+	return p.NotUsername || p.NotEmail || p.NotContainsUsername || p.NotContainsEmail || p.NotContainsDisplayName
+}
+
+// contextRule is one attribute of the user a password can't contain
+type contextRule struct {
+	rule   string
+	source string // What the words are, for messages
+	words  []string
+}
+
+// emailLocalPart is the part of an address before the last @
+func emailLocalPart(email string) string {
+	if at := strings.LastIndex(email, "@"); at != -1 {
+		return email[:at]
+	}
+	return email
+}
+
+// usernameParts is the username, or every part of it length characters long when
+// length is shorter, like libpwquality's usersubstr
+func usernameParts(username string, length int) []string {
+	runes := []rune(username)
+	if length <= 0 || length >= len(runes) {
+		return []string{username}
+	}
+	parts := make([]string, 0, len(runes)-length+1)
+	for start := 0; start+length <= len(runes); start++ {
+		parts = append(parts, string(runes[start:start+length]))
+	}
+	return parts
+}
+
+// displayNameWords splits a display name like Active Directory does, on commas,
+// periods, dashes, underscores, # and whitespace
+func displayNameWords(name string) []string {
+	return strings.FieldsFunc(name, func(r rune) bool {
+		return strings.ContainsRune(",.-_# \t", r)
+	})
+}
+
+// contextRules lists the attributes the enabled containment rules look for,
+// lowercased and without the ones too short to check
+func (p *Policy) contextRules() []contextRule {
+	var rules []contextRule
+	add := func(rule string, source string, words ...string) {
+		var kept []string
+		for _, word := range words {
+			if utf8.RuneCountInString(word) >= minContextLength {
+				kept = append(kept, strings.ToLower(word))
+			}
+		}
+		if len(kept) > 0 {
+			rules = append(rules, contextRule{rule: rule, source: source, words: kept})
+		}
+	}
+	if p.NotContainsUsername {
+		add("contains-username", "the username", usernameParts(p.user.Username, p.UsernameSubstring)...)
+	}
+	if p.NotContainsEmail {
+		add("contains-email", "the email address", emailLocalPart(p.user.Email))
+	}
+	if p.NotContainsDisplayName {
+		add("contains-display-name", "the display name", displayNameWords(p.user.DisplayName)...)
+	}
+	return rules
+}
+
+func reverse(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// containmentFailures checks the password for the user's attributes, forwards
+// and backwards
+func (p *Policy) containmentFailures(password string) []Failure {
+	var failures []Failure
+	lower := strings.ToLower(password)
+	for _, rule := range p.contextRules() {
+		for _, word := range rule.words {
+			if strings.Contains(lower, word) {
+				failures = append(failures, Failure{Rule: rule.rule, Message: fmt.Sprintf("contains %q from %s", word, rule.source)})
+				break
+			}
+			if strings.Contains(lower, reverse(word)) {
+				failures = append(failures, Failure{Rule: rule.rule, Message: fmt.Sprintf("contains %q from %s reversed", word, rule.source)})
+				break
+			}
+		}
+	}
+	return failures
+}
@@ -0,0 +1,626 @@
+package policy
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Rand is the randomness the generators need. *math/rand.Rand satisfies it for
+// reproducible runs, CryptoRand for passwords that will actually be used.
+type Rand interface {
+	Intn(n int) int
+}
+
+type cryptoRand struct{}
+
+func (cryptoRand) Intn(n int) int {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic("policy: reading crypto/rand: " + err.Error())
+	}
+	return int(i.Int64())
+}
+
+// CryptoRand draws from crypto/rand.
+var CryptoRand Rand = cryptoRand{}
+
+// generatedMaxLength caps generated passwords for policies without a maximum
+const generatedMaxLength = 100
+
+// Category is a kind of generated password along with the verdict the policy
+// should give it.
+type Category struct {
+	Name        string
+	Description string // Finishes "Running N tests that ...", like "should pass successfully"
+	Expected    bool
+	Generate    func(r Rand) string
+}
+
+func pick(r Rand, chars []rune) rune {
+	return chars[r.Intn(len(chars))]
+}
+
+func shuffle(r Rand, chars []rune) {
+	for i := len(chars) - 1; i > 0; i-- {
+		j := r.Intn(i + 1)
+		chars[i], chars[j] = chars[j], chars[i]
+	}
+}
+
+// fallbackPool is the filler when the policy has no classes to draw from
+const fallbackPool = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// poolOf lists the characters of every class except skip
+func poolOf(classes []CharClass, skip string) []rune {
+	var pool []rune
+	for _, class := range classes {
+		if class.Name != skip {
+			pool = append(pool, []rune(class.Chars)...)
+		}
+	}
+	// Policies without classes, like the one of login.defs, still need filler
+	if len(pool) == 0 && skip == "" {
+		pool = []rune(fallbackPool)
+	}
+	return pool
+}
+
+// build draws each class its minimum number of times, adds classes until there
+// are minClasses of them, fills up to length from the pool and shuffles
+func build(r Rand, classes []CharClass, pool []rune, length int, minClasses int) string {
+	var chars []rune
+	used := 0
+	var unused []CharClass
+	for _, class := range classes {
+		classChars := []rune(class.Chars)
+		for i := 0; i < class.Min && len(classChars) > 0; i++ {
+			chars = append(chars, pick(r, classChars))
+		}
+		if class.Min > 0 && len(classChars) > 0 {
+			used += 1
+		} else if len(classChars) > 0 {
+			unused = append(unused, class)
+		}
+	}
+	for ; used < minClasses && len(unused) > 0; used++ {
+		i := r.Intn(len(unused))
+		chars = append(chars, pick(r, []rune(unused[i].Chars)))
+		unused = append(unused[:i], unused[i+1:]...)
+	}
+	for len(pool) > 0 && len(chars) < length {
+		chars = append(chars, pick(r, pool))
+	}
+	shuffle(r, chars)
+	return string(chars)
+}
+
+// generateAttempts is how often a generator retries before settling for its last
+// candidate
+const generateAttempts = 100
+
+// generateUntil retries generate until want is satisfied. want must never be
+// Validate, the verdict a password should get comes from how it was built and
+// not from the validator under test.
+func generateUntil(r Rand, generate func(r Rand) string, want func(password string) bool) string {
+	candidate := ""
+	for i := 0; i < generateAttempts; i++ {
+		candidate = generate(r)
+		if want(candidate) {
+			break
+		}
+	}
+	return candidate
+}
+
+// withinLimits checks that random filler didn't break a run limit or spell out a
+// word of the user by chance
+func (p *Policy) withinLimits(password string) bool {
+	if p.MaxRepeat > 0 && utf8.RuneCountInString(longestRepeat(password)) > p.MaxRepeat {
+		return false
+	}
+	if p.MaxSequence > 0 && utf8.RuneCountInString(longestSequence(password)) > p.MaxSequence {
+		return false
+	}
+	if p.MaxKeyboardWalk > 0 && utf8.RuneCountInString(longestKeyboardWalk(password)) > p.MaxKeyboardWalk {
+		return false
+	}
+	if rules := p.contextRules(); len(rules) > 0 {
+		lower := strings.ToLower(password)
+		for _, rule := range rules {
+			for _, word := range rule.words {
+				if strings.Contains(lower, word) || strings.Contains(lower, reverse(word)) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// insert puts chars into password at a random position
+func insert(r Rand, password string, chars []rune) string {
+	runes := []rune(password)
+	i := r.Intn(len(runes) + 1)
+	return string(runes[:i]) + string(chars) + string(runes[i:])
+}
+
+// sequenceStarts lists the characters that start a run of length characters the
+// generators can use, like a for abcd
+func (p *Policy) sequenceStarts(length int) []rune {
+	chars := make(map[rune]bool)
+	for _, r := range poolOf(p.Classes, "") {
+		chars[r] = true
+	}
+	var starts []rune
+	for start := range chars {
+		run := 1
+		for run < length && chars[start+rune(run)] {
+			run += 1
+		}
+		if run == length {
+			starts = append(starts, start)
+		}
+	}
+	sort.Slice(starts, func(i, j int) bool {
+		return starts[i] < starts[j]
+	})
+	return starts
+}
+
+// repeatRun is a character the generators use, length times
+func (p *Policy) repeatRun(r Rand, length int) []rune {
+	return []rune(strings.Repeat(string(pick(r, poolOf(p.Classes, ""))), length))
+}
+
+// sequenceRun is a run of length characters from one of starts, up or down
+func sequenceRun(r Rand, starts []rune, length int) []rune {
+	start := pick(r, starts)
+	sequence := make([]rune, length)
+	for i := range sequence {
+		sequence[i] = start + rune(i)
+	}
+	if r.Intn(2) == 0 {
+		for i, j := 0, len(sequence)-1; i < j; i, j = i+1, j-1 {
+			sequence[i], sequence[j] = sequence[j], sequence[i]
+		}
+	}
+	return sequence
+}
+
+// allowedWalks lists the keyboard walks of length keys that allowed permits, with
+// only the permitted characters of each key
+func (p *Policy) allowedWalks(length int, allowed func(c rune) bool) [][][]rune {
+	var walks [][][]rune
+	for _, walk := range KeyboardWalks(length) {
+		keys := make([][]rune, 0, len(walk))
+		for _, key := range walk {
+			var chars []rune
+			for _, c := range key {
+				if allowed(c) {
+					chars = append(chars, c)
+				}
+			}
+			if len(chars) == 0 {
+				break
+			}
+			keys = append(keys, chars)
+		}
+		if len(keys) == length {
+			walks = append(walks, keys)
+		}
+	}
+	return walks
+}
+
+// walkRun picks a walk and either character of each key
+func walkRun(r Rand, walks [][][]rune) []rune {
+	walk := walks[r.Intn(len(walks))]
+	chars := make([]rune, len(walk))
+	for i, key := range walk {
+		chars[i] = pick(r, key)
+	}
+	return chars
+}
+
+// varyCase flips some letters of s, for the rules that ignore case
+func varyCase(r Rand, s string) string {
+	chars := []rune(s)
+	for i, c := range chars {
+		if r.Intn(4) == 0 && unicode.IsUpper(c) {
+			chars[i] = unicode.ToLower(c)
+		} else if r.Intn(4) == 0 {
+			chars[i] = unicode.ToUpper(c)
+		}
+	}
+	return string(chars)
+}
+
+// generatable reports whether the generators use c as a class character, which
+// is what they go by instead of the alphabet regex under test
+func (p *Policy) generatable(c rune) bool {
+	return strings.ContainsRune(string(poolOf(p.Classes, "")), c)
+}
+
+func (p *Policy) requiredChars(skip string) int {
+	required := 0
+	for _, class := range p.Classes {
+		if class.Name != skip {
+			required += class.Min
+		}
+	}
+	return required
+}
+
+// lengthRange is the range of lengths the policy accepts, with enough room for
+// every required character
+func (p *Policy) lengthRange() (int, int) {
+	low := max(p.MinLength, p.requiredChars(""), p.MinClasses, 1)
+	high := p.MaxLength
+	if high == 0 {
+		high = max(low, generatedMaxLength)
+	}
+	return low, high
+}
+
+func (p *Policy) randomLength(r Rand) int {
+	low, high := p.lengthRange()
+	if high < low {
+		return low
+	}
+	return low + r.Intn(high-low+1)
+}
+
+// Compliant generates a password the policy should accept.
+func (p *Policy) Compliant(r Rand) string {
+	return p.compliantWith(r, nil)
+}
+
+// compliantWith builds a compliant password around the characters chars draws,
+// leaving room for them in the length
+func (p *Policy) compliantWith(r Rand, chars func(r Rand) []rune) string {
+	return generateUntil(r, func(r Rand) string {
+		if chars == nil {
+			return build(r, p.Classes, poolOf(p.Classes, ""), p.randomLength(r), p.MinClasses)
+		}
+		embedded := chars(r)
+		password := build(r, p.Classes, poolOf(p.Classes, ""), p.randomLength(r)-len(embedded), p.MinClasses)
+		return insert(r, password, embedded)
+	}, p.withinLimits)
+}
+
+// unchecked builds a password from the policy's classes with no regard for the
+// run limits or the user, for categories that break another rule on purpose
+func (p *Policy) unchecked(r Rand) string {
+	return build(r, p.Classes, poolOf(p.Classes, ""), p.randomLength(r), p.MinClasses)
+}
+
+// maxShortLength is the longest password that stays too short even when every
+// character of it earns a credit
+func (p *Policy) maxShortLength() int {
+	length := p.MinLength - 1
+	for length > 0 && length+min(length, p.totalCredit) >= p.MinLength {
+		length -= 1
+	}
+	return length
+}
+
+// GenerateOptions tunes GenerateCompliant.
+type GenerateOptions struct {
+	Length       int    // 0 picks a random length the policy accepts
+	ExcludeChars string // Characters that must not appear, like ambiguous ones
+	Attempts     int    // How many candidates to try before giving up, 100 by default
+}
+
+// ErrUnsatisfiable is returned when no generated candidate was accepted.
+var ErrUnsatisfiable = errors.New("policy: couldn't generate a password the policy accepts")
+
+// GenerateCompliant generates a password and only returns it once Validate
+// accepts it.
+func (p *Policy) GenerateCompliant(r Rand, options GenerateOptions) (string, error) {
+	classes := make([]CharClass, len(p.Classes))
+	for i, class := range p.Classes {
+		class.Chars = strings.Map(func(c rune) rune {
+			if strings.ContainsRune(options.ExcludeChars, c) {
+				return -1
+			}
+			return c
+		}, class.Chars)
+		classes[i] = class
+	}
+	attempts := options.Attempts
+	if attempts <= 0 {
+		attempts = 100
+	}
+
+	for i := 0; i < attempts; i++ {
+		length := options.Length
+		if length <= 0 {
+			length = p.randomLength(r)
+		}
+		candidate := build(r, classes, poolOf(classes, ""), length, p.MinClasses)
+		if p.Accepts(candidate) {
+			return candidate, nil
+		}
+	}
+	return "", ErrUnsatisfiable
+}
+
+// Categories lists the generators for the policy: compliant passwords, and for
+// every rule passwords that break that rule. Each password gets its verdict from
+// how it's built, the policy's own validation is never asked.
+func (p *Policy) Categories() []Category {
+	categories := []Category{{
+		Name:        "should-pass",
+		Description: "should pass successfully",
+		Expected:    true,
+		Generate:    p.Compliant,
+	}}
+
+	if p.totalCredit > 0 && p.MinLength-p.totalCredit > 0 {
+		categories = append(categories, Category{
+			Name:        "should-pass-credits",
+			Description: "should pass on credits making up for a short password",
+			Expected:    true,
+			Generate: func(r Rand) string {
+				// Every credit the classes give and enough classes for MinClasses, in a
+				// password that's short without the credits
+				classes := append([]CharClass(nil), p.Classes...)
+				for i := range classes {
+					classes[i].Min = max(classes[i].Min, classes[i].Credit)
+				}
+				return generateUntil(r, func(r Rand) string {
+					return build(r, classes, poolOf(p.Classes, ""), p.MinLength-1-r.Intn(p.totalCredit), p.MinClasses)
+				}, p.withinLimits)
+			},
+		})
+	}
+
+	for _, class := range p.Classes {
+		if class.Min == 0 {
+			continue
+		}
+		categories = append(categories, Category{
+			Name:        "should-fail-" + class.Name,
+			Description: "should fail on missing " + class.Description,
+			Expected:    false,
+			Generate: func(r Rand) string {
+				// One too few of this class, which is never used as filler
+				classes := append([]CharClass(nil), p.Classes...)
+				for i := range classes {
+					if classes[i].Name == class.Name {
+						classes[i].Min -= 1
+						if classes[i].Min == 0 {
+							classes[i].Chars = ""
+						}
+					}
+				}
+				return build(r, classes, poolOf(p.Classes, class.Name), p.randomLength(r), p.MinClasses)
+			},
+		})
+	}
+
+	if short := p.maxShortLength(); p.MinLength > 1 && short > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-length",
+			Description: "should fail on too short of a password",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				generated := []rune(p.unchecked(r))
+				return string(generated[:r.Intn(min(short, len(generated)))+1])
+			},
+		})
+	}
+
+	if p.MaxLength > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-max-length",
+			Description: "should fail on too long of a password",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return build(r, p.Classes, poolOf(p.Classes, ""), p.MaxLength+1+r.Intn(16), p.MinClasses)
+			},
+		})
+	}
+
+	if p.Alphabet != "" && p.IllegalChars != "" {
+		categories = append(categories, Category{
+			Name:        "should-fail-illegal-chars",
+			Description: "should fail on illegal characters",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				illegal := []rune(p.IllegalChars)
+				chars := []rune(p.unchecked(r))
+				for n := r.Intn(25) + 1; n > 0; n-- {
+					chars = append(chars, pick(r, illegal))
+				}
+				shuffle(r, chars)
+				return string(chars)
+			},
+		})
+	}
+
+	required := 0
+	for _, class := range p.Classes {
+		if class.Min > 0 {
+			required += 1
+		}
+	}
+	if p.MinClasses > required {
+		categories = append(categories, Category{
+			Name:        "should-fail-min-classes",
+			Description: "should fail on too few kinds of characters",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				// The required classes and random others, one class short
+				var classes, optional []CharClass
+				for _, class := range p.Classes {
+					if class.Min > 0 {
+						classes = append(classes, class)
+					} else {
+						optional = append(optional, class)
+					}
+				}
+				for len(classes) < p.MinClasses-1 {
+					i := r.Intn(len(optional))
+					classes = append(classes, optional[i])
+					optional = append(optional[:i], optional[i+1:]...)
+				}
+				return build(r, classes, poolOf(classes, ""), p.randomLength(r), 0)
+			},
+		})
+	}
+
+	if p.MaxRepeat > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-max-repeat",
+			Description: "should fail on repeated characters",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return insert(r, p.unchecked(r), p.repeatRun(r, p.MaxRepeat+1+r.Intn(3)))
+			},
+		})
+	}
+	if p.MaxRepeat > 1 {
+		categories = append(categories, Category{
+			Name:        "should-pass-max-repeat",
+			Description: "should pass on as many repeated characters as allowed",
+			Expected:    true,
+			Generate: func(r Rand) string {
+				return p.compliantWith(r, func(r Rand) []rune {
+					return p.repeatRun(r, p.MaxRepeat)
+				})
+			},
+		})
+	}
+
+	if sequences := p.sequenceStarts(p.MaxSequence + 1); p.MaxSequence > 0 && len(sequences) > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-max-sequence",
+			Description: "should fail on sequences like abcd",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return insert(r, p.unchecked(r), sequenceRun(r, sequences, p.MaxSequence+1))
+			},
+		})
+	}
+	if sequences := p.sequenceStarts(p.MaxSequence); p.MaxSequence > 1 && len(sequences) > 0 {
+		categories = append(categories, Category{
+			Name:        "should-pass-max-sequence",
+			Description: "should pass on a sequence as long as allowed",
+			Expected:    true,
+			Generate: func(r Rand) string {
+				return p.compliantWith(r, func(r Rand) []rune {
+					return sequenceRun(r, sequences, p.MaxSequence)
+				})
+			},
+		})
+	}
+
+	if walks := p.allowedWalks(p.MaxKeyboardWalk+1, p.generatable); p.MaxKeyboardWalk > 0 && len(walks) > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-max-keyboard-walk",
+			Description: "should fail on keyboard walks like qwer",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return insert(r, p.unchecked(r), walkRun(r, walks))
+			},
+		})
+	}
+	if walks := p.allowedWalks(p.MaxKeyboardWalk, p.generatable); p.MaxKeyboardWalk > 1 && len(walks) > 0 {
+		categories = append(categories, Category{
+			Name:        "should-pass-max-keyboard-walk",
+			Description: "should pass on a keyboard walk as long as allowed",
+			Expected:    true,
+			Generate: func(r Rand) string {
+				return p.compliantWith(r, func(r Rand) []rune {
+					return walkRun(r, walks)
+				})
+			},
+		})
+	}
+
+	if len(p.Blocklist) > 0 {
+		categories = append(categories, Category{
+			Name:        "should-fail-blocklist",
+			Description: "should fail on commonly used passwords",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return varyCase(r, p.Blocklist[r.Intn(len(p.Blocklist))])
+			},
+		})
+	}
+
+	if p.NotUsername && p.user.Username != "" {
+		categories = append(categories, Category{
+			Name:        "should-fail-username",
+			Description: "should fail on being the username",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return varyCase(r, p.user.Username)
+			},
+		})
+	}
+	if p.NotEmail && p.user.Email != "" {
+		categories = append(categories, Category{
+			Name:        "should-fail-email",
+			Description: "should fail on being the email address",
+			Expected:    false,
+			Generate: func(r Rand) string {
+				return varyCase(r, p.user.Email)
+			},
+		})
+	}
+
+	for _, rule := range p.contextRules() {
+		attribute := strings.TrimPrefix(rule.rule, "contains-")
+		categories = append(categories, Category{
+			Name:        "should-fail-" + rule.rule,
+			Description: "should fail on containing " + rule.source,
+			Expected:    false,
+			Generate: func(r Rand) string {
+				// Embed a word of the attribute in any case, forwards or backwards
+				word := []rune(rule.words[r.Intn(len(rule.words))])
+				if r.Intn(2) == 0 {
+					word = []rune(reverse(string(word)))
+				}
+				for i, c := range word {
+					if r.Intn(3) == 0 {
+						word[i] = unicode.ToUpper(c)
+					}
+				}
+				return insert(r, p.unchecked(r), word)
+			},
+		})
+
+		// Only words of characters the generators use can end up in a compliant password
+		var allowed []string
+		for _, word := range rule.words {
+			if strings.IndexFunc(word, func(c rune) bool { return !p.generatable(c) }) == -1 {
+				allowed = append(allowed, word)
+			}
+		}
+		if len(allowed) == 0 {
+			continue
+		}
+		categories = append(categories, Category{
+			Name:        "should-pass-partial-" + attribute,
+			Description: "should pass on containing only part of " + rule.source,
+			Expected:    true,
+			Generate: func(r Rand) string {
+				// A piece too short to be the whole word, like jsmit for jsmith
+				return p.compliantWith(r, func(r Rand) []rune {
+					word := []rune(allowed[r.Intn(len(allowed))])
+					length := (len(word)+1)/2 + r.Intn(len(word)/2)
+					start := r.Intn(len(word) - length + 1)
+					return word[start : start+length]
+				})
+			},
+		})
+	}
+	return categories
+}
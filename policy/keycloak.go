@@ -0,0 +1,103 @@
+package policy
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// keycloakIgnored are Keycloak clauses that don't change which passwords are valid
+var keycloakIgnored = map[string]bool{
+	"hashAlgorithm":              true,
+	"hashIterations":             true,
+	"passwordHistory":            true,
+	"passwordAge":                true,
+	"forceExpiredPasswordChange": true,
+}
+
+// keycloakClasses are the character classes of Keycloak, which counts with
+// Character.isUpperCase, isLowerCase, isDigit and !isLetterOrDigit
+var keycloakClasses = map[string]CharClass{
+	"upperCase":    {Name: "upper", Description: "uppercase letter", Pattern: `\p{Lu}`, Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	"lowerCase":    {Name: "lower", Description: "lowercase letter", Pattern: `\p{Ll}`, Chars: "abcdefghijklmnopqrstuvwxyz"},
+	"digits":       {Name: "number", Description: "digit", Pattern: `\p{Nd}`, Chars: "0123456789"},
+	"specialChars": {Name: "special-chars", Description: "special character", Pattern: `[^\p{L}\p{Nd}]`, Chars: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"},
+}
+
+// ParseKeycloak imports a Keycloak password policy string like
+//
+//	length(8) and upperCase(1) and digits(1) and notUsername(undefined)
+//
+// Clauses that only affect storage or expiry are skipped and returned as warnings.
+// Clauses that decide validity but can't be expressed are errors.
+func ParseKeycloak(spec string) (*Policy, []string, error) {
+	return compile(ParseKeycloakDefinition(spec))
+}
+
+// ParseKeycloakDefinition is like ParseKeycloak but returns the definition before
+// New checks it, for linting.
+func ParseKeycloakDefinition(spec string) (Policy, []string, error) {
+	definition := Policy{Name: "keycloak"}
+	var warnings []string
+	seen := make(map[string]bool)
+
+	// Keycloak itself splits on " and "
+	for _, clause := range strings.Split(spec, " and ") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			return Policy{}, nil, fmt.Errorf("keycloak policy: empty clause in %q", spec)
+		}
+		name, arg := clause, ""
+		if open := strings.Index(clause, "("); open != -1 {
+			if !strings.HasSuffix(clause, ")") {
+				return Policy{}, nil, fmt.Errorf("keycloak policy: clause %q is missing its closing parenthesis", clause)
+			}
+			name, arg = clause[:open], strings.TrimSpace(clause[open+1:len(clause)-1])
+		}
+		if seen[name] {
+			return Policy{}, nil, fmt.Errorf("keycloak policy: %s is given more than once", name)
+		}
+		seen[name] = true
+
+		// Keycloak writes undefined for clauses without a value
+		number := func(fallback int) (int, error) {
+			if arg == "" || arg == "undefined" {
+				return fallback, nil
+			}
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("keycloak policy: %s needs a non-negative number, got %q", name, arg)
+			}
+			return n, nil
+		}
+
+		var err error
+		switch {
+		case name == "length":
+			definition.MinLength, err = number(8)
+		case name == "maxLength":
+			definition.MaxLength, err = number(64)
+		case keycloakClasses[name].Name != "":
+			class := keycloakClasses[name]
+			class.Min, err = number(1)
+			definition.Classes = append(definition.Classes, class)
+		case name == "notUsername":
+			definition.NotUsername = true
+		case name == "notEmail":
+			definition.NotEmail = true
+		case name == "notContainsUsername":
+			definition.NotContainsUsername = true
+		case keycloakIgnored[name]:
+			warnings = append(warnings, fmt.Sprintf("ignoring %s, it doesn't change which passwords are valid", clause))
+		case name == "regexPattern", name == "passwordBlacklist":
+			return Policy{}, nil, fmt.Errorf("keycloak policy: %s isn't supported", name)
+		default:
+			return Policy{}, nil, fmt.Errorf("keycloak policy: unknown clause %q", name)
+		}
+		if err != nil {
+			return Policy{}, nil, err
+		}
+	}
+
+	return definition, warnings, nil
+}
@@ -0,0 +1,95 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseKeycloak(t *testing.T) {
+	tests := []struct {
+		spec     string
+		check    func(t *testing.T, p *Policy)
+		warnings int
+	}{
+		{
+			spec: "length(12) and maxLength(20) and upperCase(2) and digits(1) and notUsername(undefined)",
+			check: func(t *testing.T, p *Policy) {
+				if p.MinLength != 12 || p.MaxLength != 20 {
+					t.Errorf("lengths %d-%d, want 12-20", p.MinLength, p.MaxLength)
+				}
+				if upper := p.Class("upper"); upper == nil || upper.Min != 2 {
+					t.Errorf("upper class %+v, want a minimum of 2", upper)
+				}
+				if number := p.Class("number"); number == nil || number.Min != 1 {
+					t.Errorf("number class %+v, want a minimum of 1", number)
+				}
+				if !p.NotUsername || p.NotEmail {
+					t.Errorf("NotUsername %t and NotEmail %t, want only NotUsername", p.NotUsername, p.NotEmail)
+				}
+			},
+		},
+		{
+			// Clauses without a value take Keycloak's defaults
+			spec: "length(undefined) and maxLength and lowerCase and specialChars(undefined)",
+			check: func(t *testing.T, p *Policy) {
+				if p.MinLength != 8 || p.MaxLength != 64 {
+					t.Errorf("lengths %d-%d, want the defaults 8-64", p.MinLength, p.MaxLength)
+				}
+				if len(p.Classes) != 2 || p.Classes[0].Min != 1 || p.Classes[1].Min != 1 {
+					t.Errorf("classes %+v, want lower and special-chars with a minimum of 1", p.Classes)
+				}
+			},
+		},
+		{
+			spec: "length(8) and notEmail and notContainsUsername",
+			check: func(t *testing.T, p *Policy) {
+				if !p.NotEmail || !p.NotContainsUsername {
+					t.Errorf("NotEmail %t and NotContainsUsername %t, want both", p.NotEmail, p.NotContainsUsername)
+				}
+			},
+		},
+		{
+			spec:     "length(8) and hashIterations(27500) and passwordHistory(3) and forceExpiredPasswordChange(365)",
+			check:    func(t *testing.T, p *Policy) {},
+			warnings: 3,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			p, warnings, err := ParseKeycloak(test.spec)
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if len(warnings) != test.warnings {
+				t.Errorf("got warnings %q, want %d", warnings, test.warnings)
+			}
+			test.check(t, p)
+		})
+	}
+}
+
+func TestParseKeycloakErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		err  string
+	}{
+		{"", "empty clause"},
+		{"length(8) and ", "empty clause"},
+		{"length(8", "missing its closing parenthesis"},
+		{"length(8) and length(9)", "given more than once"},
+		{"length(-1)", "needs a non-negative number"},
+		{"upperCase(many)", "needs a non-negative number"},
+		{"regexPattern(^a+$)", "regexPattern isn't supported"},
+		{"passwordBlacklist(rockyou.txt)", "passwordBlacklist isn't supported"},
+		{"length(8) and frobnicate(2)", "unknown clause"},
+		{"length(12) and maxLength(8)", "maximum length 8 is below the minimum length 12"},
+	}
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			_, _, err := ParseKeycloak(test.spec)
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
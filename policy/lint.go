@@ -0,0 +1,223 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Lint levels, from the ones that make the policy wrong to plain observations
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+	LintNote    = "note"
+)
+
+// Issue is something Lint found in a policy.
+type Issue struct {
+	Level   string `json:"level"`
+	Check   string `json:"check"`
+	Message string `json:"message"`
+}
+
+// describeRanges writes the first few characters of ranges for messages
+func describeRanges(ranges []Range) string {
+	var chars []string
+	for _, r := range ranges {
+		for c := r.Lo; c <= r.Hi && len(chars) < 8; c++ {
+			chars = append(chars, fmt.Sprintf("%q", c))
+		}
+	}
+	described := strings.Join(chars, ", ")
+	if size := rangesSize(ranges); size > len(chars) {
+		described += fmt.Sprintf(" and %d more", size-len(chars))
+	}
+	return described
+}
+
+func rangesSize(ranges []Range) int {
+	size := 0
+	for _, r := range ranges {
+		size += int(r.Hi-r.Lo) + 1
+	}
+	return size
+}
+
+// CheckDefinition lists the contradictions that keep New from compiling a policy,
+// like a maximum length below the minimum. Lint reports the rest once it compiles.
+func CheckDefinition(definition Policy) []Issue {
+	var issues []Issue
+	report := func(check string, format string, v ...any) {
+		issues = append(issues, Issue{Level: LintError, Check: check, Message: fmt.Sprintf(format, v...)})
+	}
+	if definition.MinLength < 0 || definition.MaxLength < 0 {
+		report("definition", "lengths can't be negative")
+	}
+	if definition.MaxLength > 0 && definition.MaxLength < definition.MinLength {
+		report("unsatisfiable", "maximum length %d is below the minimum length %d", definition.MaxLength, definition.MinLength)
+	}
+	if definition.MinClasses < 0 || definition.MinClasses > len(definition.Classes) {
+		report("unsatisfiable", "needs characters from %d classes, but has %d", definition.MinClasses, len(definition.Classes))
+	}
+	if definition.UsernameSubstring < 0 {
+		report("definition", "username substring length can't be negative")
+	}
+	if definition.MaxRepeat < 0 || definition.MaxSequence < 0 || definition.MaxKeyboardWalk < 0 {
+		report("definition", "repeat, sequence and keyboard walk limits can't be negative")
+	}
+	for _, class := range definition.Classes {
+		if class.Name == "" || class.Pattern == "" {
+			report("definition", "character classes need a name and a pattern")
+		}
+		if class.Min < 0 || class.Credit < 0 {
+			report("definition", "class %s: minimum and credit can't be negative", class.Name)
+		}
+	}
+	return issues
+}
+
+// visibleASCII are the characters the generators are expected to cover
+var visibleASCII = []Range{{'!', '~'}}
+
+// Lint checks the policy for rules that contradict each other, can never fail or
+// can never be met, and for generator characters that drifted from the patterns.
+func (p *Policy) Lint() []Issue {
+	p.mustBeCompiled()
+	var issues []Issue
+	report := func(level string, check string, format string, v ...any) {
+		issues = append(issues, Issue{Level: level, Check: check, Message: fmt.Sprintf(format, v...)})
+	}
+
+	// The generators only produce what Chars and IllegalChars hold, so they have to
+	// agree with the patterns
+	var illegalAllowed []string
+	for _, c := range p.IllegalChars {
+		if p.Allowed(c) {
+			illegalAllowed = append(illegalAllowed, fmt.Sprintf("%q", c))
+		}
+	}
+	if len(illegalAllowed) > 0 {
+		report(LintError, "illegal-chars", "illegal characters %s are allowed by the alphabet", strings.Join(illegalAllowed, ", "))
+	}
+	if p.Alphabet != "" && p.IllegalChars == "" {
+		report(LintNote, "illegal-chars", "there are no illegal characters to generate, so the alphabet is never tested")
+	}
+
+	required := 0
+	for i := range p.Classes {
+		class := &p.Classes[i]
+		var unmatched, forbidden []string
+		for _, c := range class.Chars {
+			if !class.regex.MatchString(string(c)) {
+				unmatched = append(unmatched, fmt.Sprintf("%q", c))
+			}
+			if !p.Allowed(c) {
+				forbidden = append(forbidden, fmt.Sprintf("%q", c))
+			}
+		}
+		if len(unmatched) > 0 {
+			report(LintError, "class-drift", "class %s generates %s, which its pattern %q doesn't match", class.Name, strings.Join(unmatched, ", "), class.Pattern)
+		}
+		if len(forbidden) > 0 {
+			report(LintError, "class-drift", "class %s generates %s, which the alphabet doesn't allow", class.Name, strings.Join(forbidden, ", "))
+		}
+
+		usable := intersectRanges(class.ranges, p.alphabetRanges)
+		if len(usable) == 0 {
+			if class.Min > 0 {
+				report(LintError, "unsatisfiable", "class %s is required, but the alphabet allows none of %q", class.Name, class.Pattern)
+			} else {
+				report(LintWarning, "unreachable", "class %s can never match, the alphabet allows none of %q", class.Name, class.Pattern)
+			}
+			continue
+		}
+		if outside := subtractRanges(class.ranges, p.alphabetRanges); len(outside) > 0 {
+			report(LintWarning, "class-alphabet", "class %s matches %s, which the alphabet doesn't allow", class.Name, describeRanges(outside))
+		}
+		missing := subtractRanges(intersectRanges(usable, visibleASCII), runesToRanges(class.Chars))
+		if len(missing) > 0 && class.Chars != "" {
+			report(LintWarning, "class-drift", "class %s matches %s, which it never generates", class.Name, describeRanges(missing))
+		}
+		if class.Min > 0 && class.Chars == "" {
+			report(LintError, "class-drift", "class %s is required but has no characters to generate", class.Name)
+		}
+		if class.Min > 0 && len([]rune(class.Chars)) == 1 && p.MaxRepeat > 0 && class.Min > p.MaxRepeat {
+			report(LintWarning, "class-drift", "class %s can only generate %q, so %d of it may break the max repeat of %d", class.Name, class.Chars, class.Min, p.MaxRepeat)
+		}
+		required += class.Min
+
+		for j := i + 1; j < len(p.Classes); j++ {
+			other := &p.Classes[j]
+			shared := intersectRanges(usable, other.ranges)
+			if len(shared) > 0 {
+				report(LintWarning, "class-overlap", "classes %s and %s both match %s, which count for both", class.Name, other.Name, describeRanges(shared))
+			}
+		}
+	}
+
+	// Lengths against what the classes need
+	if p.MaxLength > 0 && required > p.MaxLength {
+		report(LintError, "unsatisfiable", "the classes need %d characters, but the maximum length is %d", required, p.MaxLength)
+	}
+	if p.MaxLength > 0 && p.MinClasses > p.MaxLength {
+		report(LintError, "unsatisfiable", "%d classes are needed, but the maximum length is %d", p.MinClasses, p.MaxLength)
+	}
+	if p.MinLength > 0 && p.totalCredit >= p.MinLength {
+		report(LintWarning, "credits", "credits of %d make up for the whole minimum length of %d", p.totalCredit, p.MinLength)
+	}
+	if p.MinLength > 0 && required > p.MinLength {
+		report(LintNote, "min-length", "the classes need %d characters, so the minimum length of %d never decides", required, p.MinLength)
+	}
+
+	// Rules that can never fail
+	needed := 0
+	for _, class := range p.Classes {
+		if class.Min > 0 {
+			needed += 1
+		}
+	}
+	if p.MinClasses > 0 && needed >= p.MinClasses {
+		report(LintWarning, "unreachable", "%d classes are required anyway, so the minimum of %d classes never decides", needed, p.MinClasses)
+	}
+	if p.MaxLength > 0 && p.MaxRepeat >= p.MaxLength {
+		report(LintWarning, "unreachable", "max repeat %d isn't below the maximum length %d", p.MaxRepeat, p.MaxLength)
+	}
+	if p.MaxLength > 0 && p.MaxSequence >= p.MaxLength {
+		report(LintWarning, "unreachable", "max sequence %d isn't below the maximum length %d", p.MaxSequence, p.MaxLength)
+	}
+	if p.MaxLength > 0 && p.MaxKeyboardWalk >= p.MaxLength {
+		report(LintWarning, "unreachable", "max keyboard walk %d isn't below the maximum length %d", p.MaxKeyboardWalk, p.MaxLength)
+	}
+	if p.MaxKeyboardWalk > 0 && len(p.allowedWalks(p.MaxKeyboardWalk+1, p.Allowed)) == 0 {
+		report(LintWarning, "unreachable", "the alphabet allows no keyboard walk of %d keys, so max keyboard walk never decides", p.MaxKeyboardWalk+1)
+	}
+	if p.MaxSequence > 0 && len(p.sequenceStarts(p.MaxSequence+1)) == 0 {
+		report(LintNote, "max-sequence", "the generators can't build a sequence of %d characters, so max sequence is never tested", p.MaxSequence+1)
+	}
+
+	if p.HasUserRules() && p.user == (User{}) {
+		report(LintNote, "user", "the rules about the account never fail without a username, email or display name")
+	}
+
+	if len(p.Blocklist) > 0 {
+		var shadowed []string
+		for _, password := range p.Blocklist {
+			verdict := p.Validate(password)
+			if len(verdict.Failures) > 1 {
+				shadowed = append(shadowed, fmt.Sprintf("%q", password))
+			}
+		}
+		if len(shadowed) > 0 {
+			report(LintNote, "blocklist", "%d blocklist entries break other rules anyway, like %s", len(shadowed), strings.Join(shadowed[:min(len(shadowed), 3)], ", "))
+		}
+	}
+	return issues
+}
+
+// runesToRanges lists the characters of s as ranges, never nil
+func runesToRanges(s string) []Range {
+	ranges := []Range{}
+	for _, c := range s {
+		ranges = append(ranges, Range{c, c})
+	}
+	return append([]Range{}, mergeRanges(ranges)...)
+}
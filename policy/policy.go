@@ -0,0 +1,322 @@
+// Package policy holds the password policy credstester verifies, so services can
+// validate passwords with exactly the implementation that is being tested.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxRegexRepeat is the largest repetition count RE2 accepts in {min,max}
+const maxRegexRepeat = 1000
+
+// CharClass is a group of characters the policy counts, like uppercase letters.
+type CharClass struct {
+	Name        string `json:"name"`             // Short name used for rules and generator categories, like "upper"
+	Description string `json:"description"`      // Human readable name of a single character, like "uppercase letter"
+	Pattern     string `json:"pattern"`          // Regex matching one character of the class
+	Chars       string `json:"chars"`            // Characters the generators pick from
+	Min         int    `json:"min"`              // Number of characters of this class a password needs
+	Credit      int    `json:"credit,omitempty"` // Up to this many characters of the class count twice toward MinLength
+
+	regex  *regexp.Regexp
+	ranges []Range
+}
+
+// Regexp returns the compiled Pattern.
+func (c *CharClass) Regexp() *regexp.Regexp {
+	return c.regex
+}
+
+// Policy describes which passwords are accepted. Create policies with New, or use
+// Default for the policy credstester was written for.
+type Policy struct {
+	Name            string      `json:"name"`
+	MinLength       int         `json:"min_length"`
+	MaxLength       int         `json:"max_length,omitempty"` // 0 means there's no maximum
+	Alphabet        string      `json:"alphabet,omitempty"`   // Regex matching one allowed character, empty allows every character
+	Classes         []CharClass `json:"classes"`
+	IllegalChars    string      `json:"illegal_chars,omitempty"`     // Characters outside the alphabet the generators use
+	MinClasses      int         `json:"min_classes,omitempty"`       // Number of classes a password needs characters from
+	MaxRepeat       int         `json:"max_repeat,omitempty"`        // Longest run of the same character, 0 allows any
+	MaxSequence     int         `json:"max_sequence,omitempty"`      // Longest run like abcd or 4321, 0 allows any
+	MaxKeyboardWalk int         `json:"max_keyboard_walk,omitempty"` // Longest run of neighboring keys like qwer, 0 allows any
+	NotUsername     bool        `json:"not_username,omitempty"`      // The password can't be the username, ignoring case
+	NotEmail        bool        `json:"not_email,omitempty"`         // The password can't be the email address, ignoring case
+	Blocklist       []string    `json:"blocklist,omitempty"`         // Passwords that are rejected, ignoring case
+
+	// The password can't contain these, ignoring case and also reversed
+	NotContainsUsername    bool `json:"not_contains_username,omitempty"`
+	NotContainsEmail       bool `json:"not_contains_email,omitempty"`        // The local part of the email address
+	NotContainsDisplayName bool `json:"not_contains_display_name,omitempty"` // Every word of at least 3 characters
+	UsernameSubstring      int  `json:"username_substring,omitempty"`        // Also every part of the username this long, 0 only checks all of it
+
+	user           User
+	valid          *regexp.Regexp
+	alphabet       *regexp.Regexp
+	alphabetRanges []Range
+	lengthInRegex  bool
+	totalCredit    int
+	blocked        map[string]bool
+}
+
+// User is the account a password is set for, for the rules that compare the
+// password with it. Rules about an empty field always pass.
+type User struct {
+	Username    string `json:"username,omitempty"`
+	Email       string `json:"email,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+}
+
+// Failure is a single reason for a password being rejected.
+type Failure struct {
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+	Char     string `json:"char,omitempty"`
+	Position int    `json:"position,omitempty"` // 1-based position of the character in the password
+}
+
+// Verdict is the outcome of validating a password. A password is accepted when
+// it breaks no rules.
+type Verdict struct {
+	Accepted bool      `json:"accepted"`
+	Failures []Failure `json:"failures"`
+}
+
+// New validates the definition and compiles its patterns.
+func New(definition Policy) (*Policy, error) {
+	// The definition can be a copy of a compiled policy, like one with a flag changed
+	p := definition
+	p.Classes = append([]CharClass(nil), definition.Classes...)
+	p.totalCredit = 0
+	if issues := CheckDefinition(definition); len(issues) > 0 {
+		return nil, fmt.Errorf("policy %s: %s", p.Name, issues[0].Message)
+	}
+
+	var err error
+	for i := range p.Classes {
+		class := &p.Classes[i]
+		p.totalCredit += class.Credit
+		class.regex, err = regexp.Compile(class.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: class %s: %w", p.Name, class.Name, err)
+		}
+		class.ranges, err = patternRanges(class.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: class %s: %w", p.Name, class.Name, err)
+		}
+	}
+
+	if p.Alphabet != "" {
+		p.alphabet, err = regexp.Compile("^(?:" + p.Alphabet + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: alphabet: %w", p.Name, err)
+		}
+		p.alphabetRanges, err = patternRanges(p.Alphabet)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: alphabet: %w", p.Name, err)
+		}
+	}
+	if len(p.Blocklist) > 0 {
+		p.blocked = make(map[string]bool, len(p.Blocklist))
+		for _, password := range p.Blocklist {
+			p.blocked[strings.ToLower(password)] = true
+		}
+	}
+	p.lengthInRegex = p.MinLength <= maxRegexRepeat && p.MaxLength <= maxRegexRepeat && p.totalCredit == 0
+	p.valid, err = regexp.Compile(p.ValidPattern())
+	if err != nil {
+		return nil, fmt.Errorf("policy %s: %w", p.Name, err)
+	}
+	return &p, nil
+}
+
+// MustNew is like New but panics when the definition is invalid.
+func MustNew(definition Policy) *Policy {
+	p, err := New(definition)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// compile finishes an importer, passing its error on
+func compile(definition Policy, warnings []string, err error) (*Policy, []string, error) {
+	if err != nil {
+		return nil, nil, err
+	}
+	p, err := New(definition)
+	if err != nil {
+		return nil, nil, err
+	}
+	return p, warnings, nil
+}
+
+// ValidPattern is the regex a whole password has to match, checking the alphabet
+// and, when RE2 can express them, the length bounds. With credits the minimum is
+// the shortest length credits can make up for.
+func (p *Policy) ValidPattern() string {
+	alphabet := `[\s\S]`
+	if p.Alphabet != "" {
+		alphabet = "(?:" + p.Alphabet + ")"
+	}
+	// Credits can make a password shorter than MinLength long enough
+	minLength := max(p.MinLength-p.totalCredit, 0)
+	if minLength > maxRegexRepeat || p.MaxLength > maxRegexRepeat {
+		return "^" + alphabet + "*$"
+	} else if p.MaxLength > 0 {
+		return fmt.Sprintf("^%s{%d,%d}$", alphabet, minLength, p.MaxLength)
+	}
+	return fmt.Sprintf("^%s{%d,}$", alphabet, minLength)
+}
+
+// Allowed reports whether a character is part of the alphabet.
+func (p *Policy) Allowed(r rune) bool {
+	return p.alphabet == nil || p.alphabet.MatchString(string(r))
+}
+
+// Class returns the character class with the given name, or nil.
+func (p *Policy) Class(name string) *CharClass {
+	for i := range p.Classes {
+		if p.Classes[i].Name == name {
+			return &p.Classes[i]
+		}
+	}
+	return nil
+}
+
+// ForUser returns a copy of the policy that validates passwords for user.
+func (p *Policy) ForUser(user User) *Policy {
+	p.mustBeCompiled()
+	c := *p
+	c.user = user
+	return &c
+}
+
+// User returns the account set with ForUser.
+func (p *Policy) User() User {
+	return p.user
+}
+
+// Blocked reports whether the password is on the blocklist.
+func (p *Policy) Blocked(password string) bool {
+	return p.blocked[strings.ToLower(password)]
+}
+
+// userFailures checks the rules comparing the password with the user
+func (p *Policy) userFailures(password string) []Failure {
+	var failures []Failure
+	if p.NotUsername && p.user.Username != "" && strings.EqualFold(password, p.user.Username) {
+		failures = append(failures, Failure{Rule: "not-username", Message: "is the username"})
+	}
+	if p.NotEmail && p.user.Email != "" && strings.EqualFold(password, p.user.Email) {
+		failures = append(failures, Failure{Rule: "not-email", Message: "is the email address"})
+	}
+	return append(failures, p.containmentFailures(password)...)
+}
+
+func (p *Policy) mustBeCompiled() {
+	if p.valid == nil {
+		panic("policy: " + p.Name + " was not created with New")
+	}
+}
+
+func countAtLeast(regex *regexp.Regexp, password string, n int) bool {
+	if n <= 1 {
+		return n <= 0 || regex.MatchString(password)
+	}
+	return len(regex.FindAllStringIndex(password, n)) >= n
+}
+
+// Accepts reports whether the policy accepts a password. It's the fast path of
+// Validate, without collecting the reasons.
+func (p *Policy) Accepts(password string) bool {
+	p.mustBeCompiled()
+	if password == "" {
+		return false
+	}
+	for i := range p.Classes {
+		if !countAtLeast(p.Classes[i].regex, password, p.Classes[i].Min) {
+			return false
+		}
+	}
+	if !p.valid.MatchString(password) || p.Blocked(password) || len(p.userFailures(password)) > 0 || len(p.structureFailures(password)) > 0 {
+		return false
+	}
+	if !p.lengthInRegex {
+		length := utf8.RuneCountInString(password)
+		return length+p.Credits(password) >= p.MinLength && (p.MaxLength == 0 || length <= p.MaxLength)
+	}
+	return true
+}
+
+// Validate checks a password and lists every rule it breaks.
+func (p *Policy) Validate(password string) Verdict {
+	p.mustBeCompiled()
+	failures := []Failure{}
+	if password == "" {
+		failures = append(failures, Failure{Rule: "empty", Message: "password is empty"})
+		return Verdict{Failures: failures}
+	}
+
+	length := utf8.RuneCountInString(password)
+	if credits := p.Credits(password); length+credits < p.MinLength && credits > 0 {
+		failures = append(failures, Failure{Rule: "min-length", Message: fmt.Sprintf("too short (%d characters and %d credits, needs at least %d)", length, credits, p.MinLength)})
+	} else if length+credits < p.MinLength {
+		failures = append(failures, Failure{Rule: "min-length", Message: fmt.Sprintf("too short (%d characters, needs at least %d)", length, p.MinLength)})
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		failures = append(failures, Failure{Rule: "max-length", Message: fmt.Sprintf("too long (%d characters, allows at most %d)", length, p.MaxLength)})
+	}
+
+	for i := range p.Classes {
+		class := &p.Classes[i]
+		if countAtLeast(class.regex, password, class.Min) {
+			continue
+		}
+		description := class.Description
+		if description == "" {
+			description = class.Name + " character"
+		}
+		if class.Min == 1 {
+			failures = append(failures, Failure{Rule: class.Name, Message: "missing " + description})
+		} else {
+			found := len(class.regex.FindAllStringIndex(password, -1))
+			failures = append(failures, Failure{Rule: class.Name, Message: fmt.Sprintf("needs at least %d of %s, has %d", class.Min, description, found)})
+		}
+	}
+
+	position := 0
+	for _, r := range password {
+		position += 1
+		if !p.Allowed(r) {
+			failures = append(failures, Failure{Rule: "illegal-char", Message: fmt.Sprintf("contains illegal %q at position %d", r, position), Char: string(r), Position: position})
+		}
+	}
+	failures = append(failures, p.structureFailures(password)...)
+	failures = append(failures, p.userFailures(password)...)
+	if p.Blocked(password) {
+		failures = append(failures, Failure{Rule: "blocklist", Message: "is a commonly used password"})
+	}
+
+	return Verdict{Accepted: len(failures) == 0, Failures: failures}
+}
+
+// Default returns the policy credstester has always tested: at least 8 characters
+// from letters, numbers and -_.!$|@%^&*, with at least one of each kind.
+func Default() *Policy {
+	return MustNew(Policy{
+		Name:      "default",
+		MinLength: 8,
+		Alphabet:  `([A-Z]|[a-z]|[0-9]|-|_|\.|!|\$|\||@|%|\^|&|\*)`,
+		Classes: []CharClass{
+			{Name: "upper", Description: "uppercase letter", Pattern: `[A-Z]`, Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", Min: 1},
+			{Name: "lower", Description: "lowercase letter", Pattern: `[a-z]`, Chars: "abcdefghijklmnopqrstuvwxyz", Min: 1},
+			{Name: "number", Description: "number", Pattern: `[0-9]`, Chars: "0123456789", Min: 1},
+			{Name: "special-chars", Description: "special character (one of -_.!$|@%^&*)", Pattern: `([-_.!$|@%^&*])`, Chars: "-_.!$|@%^&*", Min: 1},
+		},
+		IllegalChars: "+=()#~}{[]\\<>/? \"'`,",
+	})
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"testing"
+)
+
+// everyRule is a policy with a rule of every kind, for a user
+var everyRule = MustNew(Policy{
+	Name:      "every-rule",
+	MinLength: 8,
+	MaxLength: 16,
+	Alphabet:  Default().Alphabet,
+	Classes: []CharClass{
+		{Name: "upper", Description: "uppercase letter", Pattern: `[A-Z]`, Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ", Min: 1},
+		{Name: "lower", Description: "lowercase letter", Pattern: `[a-z]`, Chars: "abcdefghijklmnopqrstuvwxyz"},
+		{Name: "number", Description: "number", Pattern: `[0-9]`, Chars: "0123456789", Min: 1},
+		{Name: "special-chars", Description: "special character", Pattern: `[-_.!$|@%^&*]`, Chars: "-_.!$|@%^&*"},
+	},
+	IllegalChars:           "#+=",
+	MinClasses:             3,
+	MaxRepeat:              2,
+	MaxSequence:            3,
+	MaxKeyboardWalk:        3,
+	NotUsername:            true,
+	NotEmail:               true,
+	NotContainsUsername:    true,
+	NotContainsEmail:       true,
+	NotContainsDisplayName: true,
+	Blocklist:              []string{"Password1.x"},
+}).ForUser(User{Username: "Qz7.mPx2k", Email: "Kt5!wRm8@ex.com", DisplayName: "Ada Lovelace"})
+
+func TestValidateRules(t *testing.T) {
+	tests := []struct {
+		password string
+		rules    []string
+	}{
+		{"Ab1.xqz7", nil},
+		{"", []string{"empty"}},
+		{"Ab1.x", []string{"min-length"}},
+		{"Ab1.xQz7mP2kLw9rT", []string{"max-length"}},
+		{"ab1.xqz7", []string{"upper"}},
+		{"Ab.xQz!mP", []string{"number"}},
+		{"Ab1.xqz#", []string{"illegal-char"}},
+		{"ABXQZMP1", []string{"min-classes"}},
+		{"Ab1.xqqq", []string{"max-repeat"}},
+		{"Ab1.bcde", []string{"max-sequence"}},
+		{"Ab1.asdf", []string{"max-keyboard-walk"}},
+		{"Qz7.mPx2k", []string{"not-username", "contains-username"}},
+		{"kT5!wrm8@EX.com", []string{"not-email", "contains-email"}},
+		{"Abk2xPm.7zQ", []string{"contains-username"}},
+		{"Ab1.Lovelace", []string{"contains-display-name"}},
+		{"PASSWORD1.X", []string{"blocklist"}},
+		{"aaaa", []string{"min-length", "upper", "number", "min-classes", "max-repeat"}},
+	}
+	for _, test := range tests {
+		verdict := everyRule.Validate(test.password)
+		var rules []string
+		for _, failure := range verdict.Failures {
+			rules = append(rules, failure.Rule)
+		}
+		if fmt.Sprint(rules) != fmt.Sprint(test.rules) {
+			t.Errorf("%q breaks %v, want %v", test.password, rules, test.rules)
+		}
+		if verdict.Accepted != (len(test.rules) == 0) {
+			t.Errorf("%q: accepted %t with failures %v", test.password, verdict.Accepted, rules)
+		}
+	}
+}
+
+// Accepts is the fast path of Validate and has to agree with it on every password
+func TestAcceptsAgreesWithValidate(t *testing.T) {
+	policies := []*Policy{Default(), everyRule}
+	for _, preset := range Presets() {
+		policies = append(policies, preset.Policy)
+	}
+	for _, path := range []string{"../testdata/pwquality/credits.conf", "../testdata/pwquality/strict.conf"} {
+		file, err := os.Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, _, err := ParsePwquality(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("%s: %s", path, err)
+		}
+		policies = append(policies, p)
+	}
+
+	for _, p := range policies {
+		r := rand.New(rand.NewSource(1))
+		for _, category := range p.Categories() {
+			for i := 0; i < 200; i++ {
+				password := category.Generate(r)
+				if accepted, verdict := p.Accepts(password), p.Validate(password); accepted != verdict.Accepted {
+					t.Errorf("policy %s, %s: Accepts(%q) = %t, Validate has failures %+v", p.Name, category.Name, password, accepted, verdict.Failures)
+				}
+			}
+		}
+	}
+}
+
+func TestUserCategories(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	want := map[string]string{"should-fail-username": "not-username", "should-fail-email": "not-email"}
+	for _, category := range everyRule.Categories() {
+		rule, ok := want[category.Name]
+		if !ok {
+			continue
+		}
+		delete(want, category.Name)
+	passwords:
+		for i := 0; i < 50; i++ {
+			password := category.Generate(r)
+			for _, failure := range everyRule.Validate(password).Failures {
+				if failure.Rule == rule {
+					continue passwords
+				}
+			}
+			t.Errorf("%s: %q doesn't break %s", category.Name, password, rule)
+		}
+	}
+	for name := range want {
+		t.Errorf("no %s category", name)
+	}
+}
@@ -0,0 +1,90 @@
+package policy
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed blocklist.txt
+var commonPasswordsText string
+
+// CommonPasswords lists the well known passwords the presets block. It's a
+// sample of the breach corpora the standards ask for, not a replacement.
+func CommonPasswords() []string {
+	return strings.Fields(commonPasswordsText)
+}
+
+// Preset is a policy modeled on the password guidance of a standard.
+type Preset struct {
+	Name     string // Name for the -preset flag, like "nist-800-63b"
+	Standard string // The standard and the sections the policy follows
+	Policy   *Policy
+}
+
+// letterClass and digitClass are the composition PCI DSS asks for
+var letterClass = CharClass{Name: "letter", Description: "letter", Pattern: `[A-Za-z]`, Chars: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", Min: 1}
+var digitClass = CharClass{Name: "number", Description: "number", Pattern: `[0-9]`, Chars: "0123456789", Min: 1}
+
+// asciiClasses are only filler for the presets without composition rules
+var asciiClasses = []CharClass{
+	{Name: "lower", Description: "lowercase letter", Pattern: `[a-z]`, Chars: "abcdefghijklmnopqrstuvwxyz"},
+	{Name: "upper", Description: "uppercase letter", Pattern: `[A-Z]`, Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+	{Name: "number", Description: "number", Pattern: `[0-9]`, Chars: "0123456789"},
+	{Name: "symbol", Description: "symbol", Pattern: `[^A-Za-z0-9]`, Chars: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~ "},
+}
+
+// Presets returns the built-in presets. NIST SP 800-63B and OWASP ASVS forbid
+// composition rules and ask for a blocklist instead, PCI DSS asks for letters and
+// numbers and says nothing about blocklists.
+func Presets() []Preset {
+	return []Preset{
+		{
+			Name:     "nist-800-63b",
+			Standard: "NIST SP 800-63B 5.1.1.2: at least 8 characters, no composition rules, blocklist of common passwords",
+			Policy: MustNew(Policy{
+				Name:      "nist-800-63b",
+				MinLength: 8,
+				Classes:   asciiClasses,
+				Blocklist: CommonPasswords(),
+			}),
+		},
+		{
+			Name:     "owasp-asvs",
+			Standard: "OWASP ASVS 4.0.3 V2.1: at least 12 characters, no composition rules, blocklist of breached passwords",
+			Policy: MustNew(Policy{
+				Name:      "owasp-asvs",
+				MinLength: 12,
+				Classes:   asciiClasses,
+				Blocklist: CommonPasswords(),
+			}),
+		},
+		{
+			Name:     "pci-dss",
+			Standard: "PCI DSS 4.0 8.3.6: at least 12 characters with both letters and numbers",
+			Policy: MustNew(Policy{
+				Name:      "pci-dss",
+				MinLength: 12,
+				Classes:   []CharClass{letterClass, digitClass},
+			}),
+		},
+	}
+}
+
+// LookupPreset finds a built-in preset by name.
+func LookupPreset(name string) (Preset, bool) {
+	for _, preset := range Presets() {
+		if preset.Name == name {
+			return preset, true
+		}
+	}
+	return Preset{}, false
+}
+
+// PresetNames lists the names of the built-in presets.
+func PresetNames() []string {
+	var names []string
+	for _, preset := range Presets() {
+		names = append(names, preset.Name)
+	}
+	return names
+}
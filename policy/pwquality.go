@@ -0,0 +1,173 @@
+package policy
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// pwqualityMinLength is the lowest minlen libpwquality accepts
+const pwqualityMinLength = 6
+
+// pwqualityMinUsersubstr is the shortest part of the username libpwquality looks
+// for, lower usersubstr values turn the check off
+const pwqualityMinUsersubstr = 4
+
+// pwqualityIgnored are settings that need a dictionary or the old password, or
+// that only change how pam_pwquality behaves
+var pwqualityIgnored = map[string]string{
+	"difok":            "needs the old password",
+	"dictcheck":        "needs the cracklib dictionary",
+	"dictpath":         "needs the cracklib dictionary",
+	"enforcing":        "only changes how pam_pwquality behaves",
+	"enforce_for_root": "only changes how pam_pwquality behaves",
+	"local_users_only": "only changes how pam_pwquality behaves",
+	"retry":            "only changes how pam_pwquality behaves",
+}
+
+// pwqualityClasses are the classes libpwquality counts, in the C locale
+func pwqualityClasses() []CharClass {
+	return []CharClass{
+		{Name: "number", Description: "digit", Pattern: `[0-9]`, Chars: "0123456789"},
+		{Name: "upper", Description: "uppercase letter", Pattern: `[A-Z]`, Chars: "ABCDEFGHIJKLMNOPQRSTUVWXYZ"},
+		{Name: "lower", Description: "lowercase letter", Pattern: `[a-z]`, Chars: "abcdefghijklmnopqrstuvwxyz"},
+		{Name: "other", Description: "other character", Pattern: `[^0-9A-Za-z]`, Chars: "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"},
+	}
+}
+
+// ParsePwquality imports a pwquality.conf. Like libpwquality, a positive credit
+// lets that many characters of a class count twice toward minlen, and a negative
+// credit requires that many characters of the class instead. Settings that can't
+// be checked from the password alone are skipped and returned as warnings.
+func ParsePwquality(r io.Reader) (*Policy, []string, error) {
+	return compile(ParsePwqualityDefinition(r))
+}
+
+// ParsePwqualityDefinition is like ParsePwquality but returns the definition
+// before New checks it, for linting.
+func ParsePwqualityDefinition(r io.Reader) (Policy, []string, error) {
+	// The defaults of libpwquality, which checks for the username unless told not to
+	definition := Policy{Name: "pwquality", MinLength: 8, Classes: pwqualityClasses(), NotContainsUsername: true}
+	credits := map[string]*CharClass{
+		"dcredit": &definition.Classes[0],
+		"ucredit": &definition.Classes[1],
+		"lcredit": &definition.Classes[2],
+		"ocredit": &definition.Classes[3],
+	}
+	var warnings []string
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, value, hasValue := strings.Cut(text, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		number := func() (int, error) {
+			n, err := strconv.Atoi(value)
+			if !hasValue || err != nil {
+				return 0, fmt.Errorf("pwquality line %d: %s needs a number, got %q", line, key, value)
+			}
+			return n, nil
+		}
+
+		var err error
+		switch {
+		case key == "minlen":
+			definition.MinLength, err = number()
+			if err == nil && definition.MinLength < pwqualityMinLength {
+				warnings = append(warnings, fmt.Sprintf("line %d: minlen %d is raised to %d like libpwquality does", line, definition.MinLength, pwqualityMinLength))
+				definition.MinLength = pwqualityMinLength
+			}
+		case credits[key] != nil:
+			var credit int
+			credit, err = number()
+			if credit >= 0 {
+				credits[key].Credit, credits[key].Min = credit, 0
+			} else {
+				credits[key].Credit, credits[key].Min = 0, -credit
+			}
+		case key == "minclass":
+			definition.MinClasses, err = number()
+			if err == nil && (definition.MinClasses < 0 || definition.MinClasses > 4) {
+				err = fmt.Errorf("pwquality line %d: minclass must be between 0 and 4", line)
+			}
+		case key == "maxrepeat":
+			definition.MaxRepeat, err = number()
+		case key == "maxsequence":
+			definition.MaxSequence, err = number()
+		case key == "usercheck":
+			var check int
+			check, err = number()
+			definition.NotContainsUsername = check != 0
+		case key == "gecoscheck":
+			var check int
+			check, err = number()
+			definition.NotContainsDisplayName = check != 0
+		case key == "usersubstr":
+			definition.UsernameSubstring, err = number()
+			if err == nil && definition.UsernameSubstring < pwqualityMinUsersubstr {
+				warnings = append(warnings, fmt.Sprintf("line %d: ignoring usersubstr %d, libpwquality only looks for parts of %d or more characters", line, definition.UsernameSubstring, pwqualityMinUsersubstr))
+				definition.UsernameSubstring = 0
+			}
+		case key == "maxclassrepeat", key == "badwords":
+			return Policy{}, nil, fmt.Errorf("pwquality line %d: %s isn't supported", line, key)
+		case pwqualityIgnored[key] != "":
+			warnings = append(warnings, fmt.Sprintf("line %d: ignoring %s, it %s", line, key, pwqualityIgnored[key]))
+		default:
+			return Policy{}, nil, fmt.Errorf("pwquality line %d: unknown setting %q", line, key)
+		}
+		if err != nil {
+			return Policy{}, nil, err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Policy{}, nil, err
+	}
+	return definition, warnings, nil
+}
+
+// ParseLoginDefs imports the password settings of a login.defs, used by the shadow
+// tools when PAM isn't. Only PASS_MIN_LEN decides which passwords are valid.
+func ParseLoginDefs(r io.Reader) (*Policy, []string, error) {
+	return compile(ParseLoginDefsDefinition(r))
+}
+
+// ParseLoginDefsDefinition is like ParseLoginDefs but returns the definition
+// before New checks it, for linting.
+func ParseLoginDefsDefinition(r io.Reader) (Policy, []string, error) {
+	definition := Policy{Name: "login.defs"}
+	var warnings []string
+
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "PASS_MIN_LEN":
+			if len(fields) != 2 {
+				return Policy{}, nil, fmt.Errorf("login.defs line %d: PASS_MIN_LEN needs a single number", line)
+			}
+			n, err := strconv.Atoi(fields[1])
+			if err != nil || n < 0 {
+				return Policy{}, nil, fmt.Errorf("login.defs line %d: PASS_MIN_LEN needs a non-negative number, got %q", line, fields[1])
+			}
+			definition.MinLength = n
+		case "PASS_MAX_LEN":
+			warnings = append(warnings, fmt.Sprintf("line %d: ignoring PASS_MAX_LEN, crypt truncates longer passwords instead of rejecting them", line))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Policy{}, nil, err
+	}
+	return definition, warnings, nil
+}
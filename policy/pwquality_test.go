@@ -0,0 +1,214 @@
+package policy
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+func parseFixture(t *testing.T, path string, parse func(r io.Reader) (*Policy, []string, error)) (*Policy, []string) {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	p, warnings, err := parse(file)
+	if err != nil {
+		t.Fatalf("%s: %s", path, err)
+	}
+	return p, warnings
+}
+
+// verdicts checks the verdict of each password, and that a rejected one breaks
+// the expected rule with the expected message
+func verdicts(t *testing.T, p *Policy, tests []verdictTest) {
+	t.Helper()
+tests:
+	for _, test := range tests {
+		verdict := p.Validate(test.password)
+		if verdict.Accepted != (test.rule == "") {
+			t.Errorf("%q: accepted %t, failures %+v", test.password, verdict.Accepted, verdict.Failures)
+			continue
+		}
+		for _, failure := range verdict.Failures {
+			if failure.Rule == test.rule && strings.Contains(failure.Message, test.message) {
+				continue tests
+			}
+		}
+		if test.rule != "" {
+			t.Errorf("%q: failures %+v, want %s with %q", test.password, verdict.Failures, test.rule, test.message)
+		}
+	}
+}
+
+type verdictTest struct {
+	password string
+	rule     string // Empty when the password is accepted
+	message  string
+}
+
+func TestParsePwqualityDefault(t *testing.T) {
+	p, warnings := parseFixture(t, "../testdata/pwquality/default.conf", ParsePwquality)
+	if len(warnings) != 0 {
+		t.Errorf("got warnings %q for a file of comments", warnings)
+	}
+	if p.MinLength != 8 || p.MinClasses != 0 || p.MaxRepeat != 0 || p.MaxSequence != 0 {
+		t.Errorf("got minlen %d, minclass %d, maxrepeat %d, maxsequence %d, want the libpwquality defaults", p.MinLength, p.MinClasses, p.MaxRepeat, p.MaxSequence)
+	}
+	if !p.NotContainsUsername {
+		t.Error("usercheck is on by default")
+	}
+	for _, class := range p.Classes {
+		if class.Min != 0 || class.Credit != 0 {
+			t.Errorf("class %s has minimum %d and credit %d, want neither", class.Name, class.Min, class.Credit)
+		}
+	}
+}
+
+func TestParsePwqualityCredits(t *testing.T) {
+	p, _ := parseFixture(t, "../testdata/pwquality/credits.conf", ParsePwquality)
+	credits := map[string]int{"number": 2, "upper": 1, "lower": 0, "other": 2}
+	for name, credit := range credits {
+		if class := p.Class(name); class.Credit != credit || class.Min != 0 {
+			t.Errorf("class %s has credit %d and minimum %d, want credit %d", name, class.Credit, class.Min, credit)
+		}
+	}
+	if p.MinLength != 12 || p.MinClasses != 3 || p.MaxRepeat != 2 {
+		t.Errorf("got minlen %d, minclass %d, maxrepeat %d, want 12, 3 and 2", p.MinLength, p.MinClasses, p.MaxRepeat)
+	}
+
+	verdicts(t, p, []verdictTest{
+		// One digit, upper and other character earn 3 credits, 7 + 3 is short of 12
+		{"Ab1!xyq", "min-length", "7 characters and 3 credits"},
+		{"Ab1!xyqw", "min-length", "8 characters and 3 credits"},
+		// Two digits and two others earn the full 5 credits, 9 + 5 is enough
+		{"Ab12!#xyz", "", ""},
+		{"Ab12!#xyz12", "", ""},
+		{"abcdefghijkl", "min-classes", "uses characters from 1 class, needs 3"},
+		{"abcdefghij12", "min-classes", "uses characters from 2 classes, needs 3"},
+		{"Ab12!#xyyyz", "max-repeat", "repeats 'y' 3 times"},
+	})
+}
+
+func TestParsePwqualityStrict(t *testing.T) {
+	p, warnings := parseFixture(t, "../testdata/pwquality/strict.conf", ParsePwquality)
+	if len(warnings) != 3 {
+		t.Errorf("got warnings %q, want difok, dictcheck and enforce_for_root", warnings)
+	}
+	for _, class := range p.Classes {
+		if class.Min != 1 || class.Credit != 0 {
+			t.Errorf("class %s has minimum %d and credit %d, want a minimum of 1", class.Name, class.Min, class.Credit)
+		}
+	}
+
+	verdicts(t, p, []verdictTest{
+		{"Qz7!mPx2Rt5#Lw", "", ""},
+		{"Qz7!mPx2Rt5#L", "min-length", "13 characters"},
+		{"Qz!mPxaRtb#Lwc", "number", "missing digit"},
+		{"Qz7!mPx2Rt5#Lwwww", "max-repeat", "repeats 'w' 4 times"},
+		{"Qz7!mPx2Rt5#Lw12345", "max-sequence", `"12345"`},
+	})
+}
+
+func TestParsePwqualitySettings(t *testing.T) {
+	tests := []struct {
+		config   string
+		check    func(p *Policy) bool
+		warnings int
+	}{
+		{"minlen = 4", func(p *Policy) bool { return p.MinLength == pwqualityMinLength }, 1},
+		{"dcredit = -2\nocredit = 3", func(p *Policy) bool { return p.Class("number").Min == 2 && p.Class("other").Credit == 3 }, 0},
+		{"usercheck = 0\ngecoscheck = 1", func(p *Policy) bool { return !p.NotContainsUsername && p.NotContainsDisplayName }, 0},
+		{"usersubstr = 4", func(p *Policy) bool { return p.UsernameSubstring == 4 }, 0},
+		{"usersubstr = 3", func(p *Policy) bool { return p.UsernameSubstring == 0 }, 1},
+		{"retry = 3\nlocal_users_only", func(p *Policy) bool { return true }, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.config, func(t *testing.T) {
+			p, warnings, err := ParsePwquality(strings.NewReader(test.config))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if !test.check(p) {
+				t.Errorf("policy %+v doesn't follow %q", p, test.config)
+			}
+			if len(warnings) != test.warnings {
+				t.Errorf("got warnings %q, want %d", warnings, test.warnings)
+			}
+		})
+	}
+}
+
+func TestParsePwqualityErrors(t *testing.T) {
+	tests := []struct {
+		config string
+		err    string
+	}{
+		{"minlen = eight", "line 1: minlen needs a number"},
+		{"# comment\nminlen", "line 2: minlen needs a number"},
+		{"minclass = 5", "minclass must be between 0 and 4"},
+		{"maxclassrepeat = 2", "maxclassrepeat isn't supported"},
+		{"badwords = acme", "badwords isn't supported"},
+		{"frobnicate = 1", "unknown setting"},
+		{"maxrepeat = -1", "limits can't be negative"},
+	}
+	for _, test := range tests {
+		t.Run(test.config, func(t *testing.T) {
+			_, _, err := ParsePwquality(strings.NewReader(test.config))
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("got error %v, want one containing %q", err, test.err)
+			}
+		})
+	}
+}
+
+func TestParseLoginDefs(t *testing.T) {
+	p, warnings := parseFixture(t, "../testdata/login.defs", ParseLoginDefs)
+	if p.MinLength != 10 {
+		t.Errorf("got a minimum length of %d, want PASS_MIN_LEN 10", p.MinLength)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "PASS_MAX_LEN") {
+		t.Errorf("got warnings %q, want one about PASS_MAX_LEN", warnings)
+	}
+	verdicts(t, p, []verdictTest{
+		{"aaaaaaaaaa", "", ""},
+		{"aaaaaaaaa", "min-length", "9 characters"},
+	})
+
+	for _, config := range []string{"PASS_MIN_LEN", "PASS_MIN_LEN 8 9", "PASS_MIN_LEN -1", "PASS_MIN_LEN eight"} {
+		if _, _, err := ParseLoginDefs(strings.NewReader(config)); err == nil {
+			t.Errorf("%q: expected an error", config)
+		}
+	}
+}
+
+// Credit characters alone can cover fewer classes than minclass asks for
+func TestShouldPassCreditsMinClasses(t *testing.T) {
+	for _, config := range []string{"minlen = 8\ndcredit = 1\nminclass = 3", "minlen = 8\ndcredit = 1\nminclass = 4"} {
+		p, _, err := ParsePwquality(strings.NewReader(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := rand.New(rand.NewSource(1))
+		found := false
+		for _, category := range p.Categories() {
+			if category.Name != "should-pass-credits" {
+				continue
+			}
+			found = true
+			for i := 0; i < 500; i++ {
+				password := category.Generate(r)
+				if verdict := p.Validate(password); !verdict.Accepted {
+					t.Errorf("%q: %q is rejected: %+v", config, password, verdict.Failures)
+					break
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%q: no should-pass-credits category", config)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"fmt"
+	"regexp/syntax"
+	"sort"
+	"unicode"
+)
+
+// Range is an inclusive range of characters.
+type Range struct {
+	Lo, Hi rune
+}
+
+// mergeRanges sorts ranges and joins the ones that overlap or touch
+func mergeRanges(ranges []Range) []Range {
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].Lo < ranges[j].Lo
+	})
+	var merged []Range
+	for _, r := range ranges {
+		if n := len(merged); n > 0 && r.Lo <= merged[n-1].Hi+1 {
+			merged[n-1].Hi = max(merged[n-1].Hi, r.Hi)
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// regexRanges lists the characters a regex matching exactly one character
+// accepts, so the policy can be written out in other regex dialects
+func regexRanges(re *syntax.Regexp) ([]Range, bool) {
+	switch re.Op {
+	case syntax.OpCapture:
+		return regexRanges(re.Sub[0])
+	case syntax.OpLiteral:
+		if len(re.Rune) != 1 {
+			return nil, false
+		}
+		ranges := []Range{{re.Rune[0], re.Rune[0]}}
+		if re.Flags&syntax.FoldCase != 0 {
+			for f := unicode.SimpleFold(re.Rune[0]); f != re.Rune[0]; f = unicode.SimpleFold(f) {
+				ranges = append(ranges, Range{f, f})
+			}
+		}
+		return mergeRanges(ranges), true
+	case syntax.OpCharClass:
+		var ranges []Range
+		for i := 0; i+1 < len(re.Rune); i += 2 {
+			ranges = append(ranges, Range{re.Rune[i], re.Rune[i+1]})
+		}
+		return mergeRanges(ranges), true
+	case syntax.OpAnyChar:
+		return []Range{{0, unicode.MaxRune}}, true
+	case syntax.OpAnyCharNotNL:
+		return []Range{{0, '\n' - 1}, {'\n' + 1, unicode.MaxRune}}, true
+	case syntax.OpAlternate:
+		var ranges []Range
+		for _, sub := range re.Sub {
+			subRanges, ok := regexRanges(sub)
+			if !ok {
+				return nil, false
+			}
+			ranges = append(ranges, subRanges...)
+		}
+		return mergeRanges(ranges), true
+	}
+	return nil, false
+}
+
+func patternRanges(pattern string) ([]Range, error) {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	ranges, ok := regexRanges(re.Simplify())
+	if !ok {
+		return nil, fmt.Errorf("%s doesn't match exactly one character", pattern)
+	}
+	return ranges, nil
+}
+
+// Ranges lists the characters of the class.
+func (c *CharClass) Ranges() []Range {
+	return c.ranges
+}
+
+// AlphabetRanges lists the allowed characters, nil when every character is allowed.
+func (p *Policy) AlphabetRanges() []Range {
+	return p.alphabetRanges
+}
+
+// subtractRanges lists the characters of a that aren't in b, both merged and nil
+// meaning every character
+func subtractRanges(a []Range, b []Range) []Range {
+	if b == nil {
+		return []Range{}
+	}
+	if a == nil {
+		a = []Range{{0, unicode.MaxRune}}
+	}
+	rest := []Range{}
+	for _, r := range a {
+		lo := r.Lo
+		for _, cut := range b {
+			if cut.Hi < lo || cut.Lo > r.Hi {
+				continue
+			}
+			if cut.Lo > lo {
+				rest = append(rest, Range{lo, cut.Lo - 1})
+			}
+			lo = cut.Hi + 1
+			if lo > r.Hi {
+				break
+			}
+		}
+		if lo <= r.Hi {
+			rest = append(rest, Range{lo, r.Hi})
+		}
+	}
+	return rest
+}
+
+// intersectRanges lists the characters in both a and b
+func intersectRanges(a []Range, b []Range) []Range {
+	return subtractRanges(a, subtractRanges(a, b))
+}
@@ -0,0 +1,183 @@
+package policy
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Credits is how many extra characters the password's classes count for, at most
+// Credit per class.
+func (p *Policy) Credits(password string) int {
+	if p.totalCredit == 0 {
+		return 0
+	}
+	credits := 0
+	for i := range p.Classes {
+		class := &p.Classes[i]
+		if class.Credit > 0 {
+			credits += min(len(class.regex.FindAllStringIndex(password, class.Credit)), class.Credit)
+		}
+	}
+	return credits
+}
+
+// ClassesUsed counts the classes the password has at least one character of.
+func (p *Policy) ClassesUsed(password string) int {
+	used := 0
+	for i := range p.Classes {
+		if p.Classes[i].regex.MatchString(password) {
+			used += 1
+		}
+	}
+	return used
+}
+
+// longestRepeat finds the longest run of the same character
+func longestRepeat(password string) string {
+	runes := []rune(password)
+	longest := ""
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && runes[end] == runes[start] {
+			end += 1
+		}
+		if end-start > len([]rune(longest)) {
+			longest = string(runes[start:end])
+		}
+		start = end
+	}
+	return longest
+}
+
+// longestSequence finds the longest run of characters that go up or down by one,
+// like abcd or 4321
+func longestSequence(password string) string {
+	runes := []rune(password)
+	longest := ""
+	for start := 0; start < len(runes); {
+		end := start + 1
+		if end < len(runes) && (runes[end]-runes[start] == 1 || runes[end]-runes[start] == -1) {
+			step := runes[end] - runes[start]
+			for end < len(runes) && runes[end]-runes[end-1] == step {
+				end += 1
+			}
+		}
+		if end-start > len([]rune(longest)) {
+			longest = string(runes[start:end])
+		}
+		// A run can start where the previous one ended, like the 3 in 1232
+		start = max(end-1, start+1)
+	}
+	return longest
+}
+
+// keyboardRows are the rows of a US QWERTY keyboard, unshifted and shifted
+var keyboardRows = [][2]string{
+	{"`1234567890-=", "~!@#$%^&*()_+"},
+	{"qwertyuiop[]\\", "QWERTYUIOP{}|"},
+	{"asdfghjkl;'", "ASDFGHJKL:\""},
+	{"zxcvbnm,./", "ZXCVBNM<>?"},
+}
+
+// keyPosition is where a character is on the keyboard, both of its characters
+// share it
+type keyPosition struct {
+	row, column int
+}
+
+var keyPositions = func() map[rune]keyPosition {
+	positions := make(map[rune]keyPosition)
+	for row, keys := range keyboardRows {
+		for _, layer := range keys {
+			for column, c := range []rune(layer) {
+				positions[c] = keyPosition{row, column}
+			}
+		}
+	}
+	return positions
+}()
+
+// KeyboardWalks lists every run of length neighboring keys along a keyboard row,
+// in both directions. Each key holds its unshifted and shifted character, like
+// "qQ" or "1!".
+func KeyboardWalks(length int) [][]string {
+	var walks [][]string
+	for _, keys := range keyboardRows {
+		unshifted, shifted := []rune(keys[0]), []rune(keys[1])
+		for start := 0; start+length <= len(unshifted) && length > 1; start++ {
+			forward := make([]string, length)
+			backward := make([]string, length)
+			for i := 0; i < length; i++ {
+				forward[i] = string(unshifted[start+i]) + string(shifted[start+i])
+				backward[length-1-i] = forward[i]
+			}
+			walks = append(walks, forward, backward)
+		}
+	}
+	return walks
+}
+
+// longestKeyboardWalk finds the longest run of keys next to each other on a row,
+// ignoring shift, like qwer or !@#$
+func longestKeyboardWalk(password string) string {
+	runes := []rune(password)
+	// step is how many keys the character at i is right of the one before it,
+	// 0 when they aren't on the same row
+	step := func(i int) int {
+		previous, previousOK := keyPositions[runes[i-1]]
+		current, currentOK := keyPositions[runes[i]]
+		if !previousOK || !currentOK || previous.row != current.row {
+			return 0
+		}
+		return current.column - previous.column
+	}
+	longest := ""
+	for start := 0; start < len(runes); {
+		end := start + 1
+		if end < len(runes) && (step(end) == 1 || step(end) == -1) {
+			direction := step(end)
+			for end < len(runes) && step(end) == direction {
+				end += 1
+			}
+		}
+		if end-start > len([]rune(longest)) {
+			longest = string(runes[start:end])
+		}
+		start = max(end-1, start+1)
+	}
+	return longest
+}
+
+// structureFailures checks the rules about how the characters are put together
+func (p *Policy) structureFailures(password string) []Failure {
+	var failures []Failure
+	if p.MinClasses > 0 {
+		if used := p.ClassesUsed(password); used < p.MinClasses {
+			var names []string
+			for _, class := range p.Classes {
+				names = append(names, class.Name)
+			}
+			classes := "classes"
+			if used == 1 {
+				classes = "class"
+			}
+			failures = append(failures, Failure{Rule: "min-classes", Message: fmt.Sprintf("uses characters from %d %s, needs %d of %s", used, classes, p.MinClasses, strings.Join(names, ", "))})
+		}
+	}
+	if p.MaxRepeat > 0 {
+		if repeat := longestRepeat(password); len([]rune(repeat)) > p.MaxRepeat {
+			failures = append(failures, Failure{Rule: "max-repeat", Message: fmt.Sprintf("repeats %q %d times in a row, allows at most %d", []rune(repeat)[0], len([]rune(repeat)), p.MaxRepeat)})
+		}
+	}
+	if p.MaxSequence > 0 {
+		if sequence := longestSequence(password); len([]rune(sequence)) > p.MaxSequence {
+			failures = append(failures, Failure{Rule: "max-sequence", Message: fmt.Sprintf("contains the sequence %q, allows at most %d characters in sequence", sequence, p.MaxSequence)})
+		}
+	}
+	if p.MaxKeyboardWalk > 0 {
+		if walk := longestKeyboardWalk(password); len([]rune(walk)) > p.MaxKeyboardWalk {
+			failures = append(failures, Failure{Rule: "max-keyboard-walk", Message: fmt.Sprintf("contains the keyboard walk %q, allows at most %d neighboring keys", walk, p.MaxKeyboardWalk)})
+		}
+	}
+	return failures
+}
@@ -0,0 +1,128 @@
+package policy
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestLongestRepeat(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", ""},
+		{"a", "a"},
+		{"aaab", "aaa"},
+		{"baaa", "aaa"},
+		{"abbbcc", "bbb"},
+		{"aabb", "aa"},
+		// Case matters, a and A are different characters
+		{"aAaA", "a"},
+		{"ééé1", "ééé"},
+		{"x😀😀", "😀😀"},
+	}
+	for _, test := range tests {
+		if got := longestRepeat(test.password); got != test.want {
+			t.Errorf("longestRepeat(%q) = %q, want %q", test.password, got, test.want)
+		}
+	}
+}
+
+func TestLongestSequence(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", ""},
+		{"x", "x"},
+		{"abcd", "abcd"},
+		{"dcba", "dcba"},
+		{"xabc", "abc"},
+		{"4321x", "4321"},
+		// A run can start where the previous one turned around
+		{"1232", "123"},
+		{"x3210", "3210"},
+		{"abab", "ab"},
+		{"acegi", "a"},
+		// Sequences don't wrap around
+		{"yzab", "yz"},
+		{"8901", "89"},
+		// Code points, not letters: case breaks a run and { follows z
+		{"aBcD", "a"},
+		{"xyz{", "xyz{"},
+		{"αβγ", "αβγ"},
+		{"٣٤٥", "٣٤٥"},
+	}
+	for _, test := range tests {
+		if got := longestSequence(test.password); got != test.want {
+			t.Errorf("longestSequence(%q) = %q, want %q", test.password, got, test.want)
+		}
+	}
+}
+
+func TestLongestKeyboardWalk(t *testing.T) {
+	tests := []struct {
+		password string
+		want     string
+	}{
+		{"", ""},
+		{"x", "x"},
+		{"qwer", "qwer"},
+		{"rewq", "rewq"},
+		{"xqwer", "qwer"},
+		{"asdfx", "asdf"},
+		// Shift doesn't matter, both characters of a key share it
+		{"QwEr", "QwEr"},
+		{"!@#$", "!@#$"},
+		{"1@3", "1@3"},
+		{"1!", "1"},
+		// Walks stay on one row and don't wrap around
+		{"opas", "op"},
+		{"qaz", "q"},
+		{"0-=`", "0-="},
+		{"l;'", "l;'"},
+		{"p[]\\", "p[]\\"},
+		{"qwqw", "qw"},
+		{"xé", "x"},
+		{"éqw", "qw"},
+	}
+	for _, test := range tests {
+		if got := longestKeyboardWalk(test.password); got != test.want {
+			t.Errorf("longestKeyboardWalk(%q) = %q, want %q", test.password, got, test.want)
+		}
+	}
+}
+
+func TestRunLimits(t *testing.T) {
+	tests := []struct {
+		limit    int
+		password string
+		rules    []string
+	}{
+		// 0 turns the rules off
+		{0, "aaaa1234qwer", nil},
+		{1, "a", nil},
+		{1, "aa", []string{"max-repeat"}},
+		{1, "ab", []string{"max-sequence"}},
+		{1, "qw", []string{"max-keyboard-walk"}},
+		{1, "ac", nil},
+		{2, "aabc", []string{"max-sequence"}},
+		{2, "aabd", nil},
+		{2, "aaa", []string{"max-repeat"}},
+		{3, "abc1qaz", nil},
+		{3, "abcd", []string{"max-sequence"}},
+		{3, "1234", []string{"max-sequence", "max-keyboard-walk"}},
+		{3, "xasdf", []string{"max-keyboard-walk"}},
+		{3, "1111x", []string{"max-repeat"}},
+	}
+	for _, test := range tests {
+		p := MustNew(Policy{Name: "runs", MinLength: 1, MaxRepeat: test.limit, MaxSequence: test.limit, MaxKeyboardWalk: test.limit})
+		var rules []string
+		for _, failure := range p.Validate(test.password).Failures {
+			rules = append(rules, failure.Rule)
+		}
+		if fmt.Sprint(rules) != fmt.Sprint(test.rules) {
+			t.Errorf("limit %d: %q breaks %v, want %v", test.limit, test.password, rules, test.rules)
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// Result is the verdict for a single generated password.
+type Result struct {
+	Category string
+	Password string
+	Expected bool
+	Actual   bool
+}
+
+// Progress reports how far a category has come. It's sent every progress
+// interval and once more when the category is done.
+type Progress struct {
+	Category string
+	Done     int
+	Total    int
+	Elapsed  time.Duration
+}
+
+// Runner generates passwords for each category and runs them through a validator.
+type Runner struct {
+	policy           *Policy
+	iterations       int
+	categories       []string
+	seed             int64
+	validator        func(password string) bool
+	progress         func(Progress)
+	progressInterval int
+}
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithIterations sets how many passwords are generated per category, 100 by default.
+func WithIterations(n int) Option {
+	return func(r *Runner) {
+		r.iterations = n
+	}
+}
+
+// WithCategories limits the run to the named categories. All categories of the
+// policy run by default.
+func WithCategories(names ...string) Option {
+	return func(r *Runner) {
+		r.categories = names
+	}
+}
+
+// WithSeed makes the generated passwords reproducible. Runs are seeded from the
+// current time by default.
+func WithSeed(seed int64) Option {
+	return func(r *Runner) {
+		r.seed = seed
+	}
+}
+
+// WithValidator replaces the policy's own Accepts, to test another implementation
+// of the same policy. Every category calls it from its own goroutine.
+func WithValidator(validator func(password string) bool) Option {
+	return func(r *Runner) {
+		r.validator = validator
+	}
+}
+
+// WithProgress calls report every interval passwords of a category, and when the
+// category is done.
+func WithProgress(interval int, report func(Progress)) Option {
+	return func(r *Runner) {
+		r.progressInterval = interval
+		r.progress = report
+	}
+}
+
+// NewRunner creates a runner for a policy.
+func NewRunner(p *Policy, options ...Option) *Runner {
+	r := &Runner{
+		policy:     p,
+		iterations: 100,
+		seed:       time.Now().UnixNano(),
+		validator:  p.Accepts,
+	}
+	for _, option := range options {
+		option(r)
+	}
+	return r
+}
+
+// Seed returns the seed the runner generates passwords from.
+func (r *Runner) Seed() int64 {
+	return r.seed
+}
+
+// Categories returns the categories the runner will run.
+func (r *Runner) Categories() []Category {
+	all := r.policy.Categories()
+	if r.categories == nil {
+		return all
+	}
+	var selected []Category
+	for _, category := range all {
+		for _, name := range r.categories {
+			if category.Name == name {
+				selected = append(selected, category)
+			}
+		}
+	}
+	return selected
+}
+
+// CategorySeed is the seed of a single category, so its passwords can be
+// regenerated on their own.
+func CategorySeed(seed int64, category string) int64 {
+	for _, c := range category {
+		seed = seed*31 + int64(c)
+	}
+	return seed
+}
+
+// Run generates the passwords of every category concurrently and hands each
+// result to handle, one at a time. The run stops at the first error handle returns.
+func (r *Runner) Run(handle func(Result) error) error {
+	results := make(chan Result)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	for _, category := range r.Categories() {
+		wg.Add(1)
+		go func(category Category) {
+			defer wg.Done()
+			rng := rand.New(rand.NewSource(CategorySeed(r.seed, category.Name)))
+			start := time.Now()
+			for i := 0; i < r.iterations; i++ {
+				password := category.Generate(rng)
+				result := Result{
+					Category: category.Name,
+					Password: password,
+					Expected: category.Expected,
+					Actual:   r.validator(password),
+				}
+				select {
+				case results <- result:
+				case <-stop:
+					return
+				}
+				if r.progress != nil && r.progressInterval > 0 && (i+1)%r.progressInterval == 0 && i+1 < r.iterations {
+					r.progress(Progress{Category: category.Name, Done: i + 1, Total: r.iterations, Elapsed: time.Since(start)})
+				}
+			}
+			if r.progress != nil {
+				r.progress(Progress{Category: category.Name, Done: r.iterations, Total: r.iterations, Elapsed: time.Since(start)})
+			}
+		}(category)
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	for result := range results {
+		if err := handle(result); err != nil {
+			close(stop)
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const resultsFile = "results.csv"
+const countsFile = "results-counts.csv"
+
+var resultsHeader = []string{"password", "expected", "actual", "category"}
+var countsHeader = []string{"category", "true_accepts", "true_rejects", "false_accepts", "false_rejects", "results_rows"}
+
+// categoryCounts tallies every result of a category, so totals survive even when
+// passing rows are not written to results.csv
+type categoryCounts struct {
+	trueAccepts  int
+	trueRejects  int
+	falseAccepts int
+	falseRejects int
+	rows         int // Rows of the category written to results.csv
+}
+
+func (c *categoryCounts) add(expected bool, actual bool) {
+	switch {
+	case expected && actual:
+		c.trueAccepts += 1
+	case !expected && !actual:
+		c.trueRejects += 1
+	case !expected && actual:
+		c.falseAccepts += 1
+	default:
+		c.falseRejects += 1
+	}
+}
+
+func (c *categoryCounts) total() int {
+	return c.trueAccepts + c.trueRejects + c.falseAccepts + c.falseRejects
+}
+
+func (c *categoryCounts) failed() int {
+	return c.falseAccepts + c.falseRejects
+}
+
+func sortedCategories(counts map[string]*categoryCounts) []string {
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func writeCounts(path string, counts map[string]*categoryCounts) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writer := csv.NewWriter(file)
+	err = writer.Write(countsHeader)
+	for _, category := range sortedCategories(counts) {
+		if err != nil {
+			break
+		}
+		c := counts[category]
+		err = writer.Write([]string{category, strconv.Itoa(c.trueAccepts), strconv.Itoa(c.trueRejects), strconv.Itoa(c.falseAccepts), strconv.Itoa(c.falseRejects), strconv.Itoa(c.rows)})
+	}
+	writer.Flush()
+	if err == nil {
+		err = writer.Error()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// readCounts returns nil counts and no error when the counts file doesn't exist
+func readCounts(path string) (map[string]*categoryCounts, error) {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if strings.Join(header, ",") != strings.Join(countsHeader, ",") {
+		return nil, fmt.Errorf("%s: unexpected header %q, run the tests again to rewrite it", path, strings.Join(header, ","))
+	}
+	reader.FieldsPerRecord = len(countsHeader)
+
+	counts := make(map[string]*categoryCounts)
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		values := make([]int, len(line)-1)
+		for i, field := range line[1:] {
+			values[i], err = strconv.Atoi(field)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid count for category %s: %w", path, line[0], err)
+			}
+		}
+		counts[line[0]] = &categoryCounts{
+			trueAccepts:  values[0],
+			trueRejects:  values[1],
+			falseAccepts: values[2],
+			falseRejects: values[3],
+			rows:         values[4],
+		}
+	}
+	return counts, nil
+}
+
+// countsMismatch explains why counts don't belong to the results read into rows,
+// or returns an empty string when every category has as many rows as recorded
+func countsMismatch(counts map[string]*categoryCounts, rows map[string]*categoryCounts) string {
+	for category, c := range rows {
+		if counts[category] == nil {
+			return fmt.Sprintf("category %s isn't in %s", categoryName(category), countsFile)
+		}
+		if counts[category].rows != c.total() {
+			return fmt.Sprintf("%s has %d rows of category %s, %s expects %d", resultsFile, c.total(), categoryName(category), countsFile, counts[category].rows)
+		}
+	}
+	for category, c := range counts {
+		if rows[category] == nil && c.rows > 0 {
+			return fmt.Sprintf("%s has no rows of category %s, %s expects %d", resultsFile, categoryName(category), countsFile, c.rows)
+		}
+	}
+	return ""
+}
+
+// readResults calls handle for every row of a results file. Older files without a
+// category column are reported under an empty category.
+func readResults(path string, handle func(result testResults)) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("%s: error while reading header: %w", path, err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range resultsHeader[:3] {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("%s: missing %s column", path, name)
+		}
+	}
+	categoryColumn, hasCategory := columns["category"]
+
+	for {
+		line, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+
+		expected, err := strconv.ParseBool(strings.TrimSpace(line[columns["expected"]]))
+		if err != nil {
+			return fmt.Errorf("%s: invalid expected result: %w", path, err)
+		}
+		actual, err := strconv.ParseBool(strings.TrimSpace(line[columns["actual"]]))
+		if err != nil {
+			return fmt.Errorf("%s: invalid actual result: %w", path, err)
+		}
+		result := testResults{
+			expectedResult: expected,
+			actualResult:   actual,
+			testedPassword: line[columns["password"]],
+		}
+		if hasCategory {
+			result.category = line[categoryColumn]
+		}
+		handle(result)
+	}
+}
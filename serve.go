@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+// validateRequest is the body of POST /validate. User replaces the account given
+// on the command line for this password only.
+type validateRequest struct {
+	Password *string      `json:"password"`
+	User     *policy.User `json:"user"`
+}
+
+type generateResponse struct {
+	Passwords []string `json:"passwords"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// statusRecorder keeps the status code for the access log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// logRequests logs the method, path and status of every request. Bodies and query
+// strings are never logged, they can contain passwords.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, recorder.status, formatElapsed(time.Since(start)))
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
+
+func validateHandler(maxBody int64) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
+		decoder := json.NewDecoder(r.Body)
+		decoder.DisallowUnknownFields()
+
+		var request validateRequest
+		err := decoder.Decode(&request)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, "request body is larger than "+strconv.FormatInt(maxBody, 10)+" bytes")
+			return
+		} else if err != nil {
+			writeError(w, http.StatusBadRequest, "expected a JSON object like {\"password\": \"...\"}")
+			return
+		}
+		if request.Password == nil {
+			writeError(w, http.StatusBadRequest, "missing password")
+			return
+		}
+		p := activePolicy
+		if request.User != nil {
+			p = activePolicy.ForUser(*request.User)
+		}
+		writeJSON(w, http.StatusOK, p.Validate(*request.Password))
+	}
+}
+
+func generateHandler(maxCount int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		count := 1
+		options := policy.GenerateOptions{Length: max(generateLength, activePolicy.MinLength)}
+		if activePolicy.MaxLength > 0 {
+			options.Length = min(options.Length, activePolicy.MaxLength)
+		}
+		var err error
+		if value := query.Get("n"); value != "" {
+			count, err = strconv.Atoi(value)
+			if err != nil || count <= 0 || count > maxCount {
+				writeError(w, http.StatusBadRequest, "n must be between 1 and "+strconv.Itoa(maxCount))
+				return
+			}
+		}
+		if value := query.Get("length"); value != "" {
+			options.Length, err = strconv.Atoi(value)
+			if err != nil || options.Length < activePolicy.MinLength || (activePolicy.MaxLength > 0 && options.Length > activePolicy.MaxLength) {
+				writeError(w, http.StatusBadRequest, "length isn't allowed by the policy")
+				return
+			}
+		}
+		if value := query.Get("exclude-ambiguous"); value != "" {
+			exclude, err := strconv.ParseBool(value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "exclude-ambiguous must be true or false")
+				return
+			}
+			if exclude {
+				options.ExcludeChars = ambiguousChars
+			}
+		}
+
+		response := generateResponse{Passwords: make([]string, 0, count)}
+		for i := 0; i < count; i++ {
+			generated, err := activePolicy.GenerateCompliant(policy.CryptoRand, options)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			response.Passwords = append(response.Passwords, generated)
+		}
+		writeJSON(w, http.StatusOK, response)
+	}
+}
+
+func newServeMux(maxBody int64, maxCount int) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("POST /validate", validateHandler(maxBody))
+	mux.Handle("GET /generate", generateHandler(maxCount))
+	mux.HandleFunc("GET /policy", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, activePolicy)
+	})
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+	return mux
+}
+
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	maxBody := fs.Int64("max-body", 4096, "Largest request body accepted by /validate, in bytes")
+	maxCount := fs.Int("max-generate", 100, "Most passwords a single /generate request can ask for")
+	addPolicyFlags(fs)
+	fs.Usage = func() {
+		printUsage(fs, "serve [flags]", "Serves the policy over HTTP:\n"+
+			"  POST /validate  {\"password\": \"...\", \"user\": {\"username\": \"...\", \"email\": \"...\", \"display_name\": \"...\"}},\n"+
+			"                  returns the verdict and the rules it breaks, user is optional\n"+
+			"  GET  /generate  ?n=&length=&exclude-ambiguous=, returns compliant passwords of 16 characters by default\n"+
+			"  GET  /policy    returns the policy definition\n"+
+			"  GET  /healthz   returns {\"status\": \"ok\"}\n"+
+			"Submitted passwords are never logged.")
+	}
+	fs.Parse(args)
+	applyPolicyFlags(fs)
+	if fs.NArg() > 0 {
+		usageError(fs, "unexpected argument %q", fs.Arg(0))
+	}
+	if *maxBody <= 0 || *maxCount <= 0 {
+		usageError(fs, "-max-body and -max-generate must be positive numbers")
+	}
+
+	server := &http.Server{
+		Addr:              *addr,
+		Handler:           logRequests(newServeMux(*maxBody, *maxCount)),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       10 * time.Second,
+		WriteTimeout:      10 * time.Second,
+		MaxHeaderBytes:    8 << 10,
+	}
+	log.Printf("Serving policy %s on %s", activePolicy.Name, *addr)
+	err := server.ListenAndServe()
+	if err != nil {
+		fatalIO("Error while serving: ", err)
+	}
+}
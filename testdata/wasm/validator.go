@@ -0,0 +1,42 @@
+// Example validator for "credstester run -wasm", built as a WASI reactor:
+//
+//	GOOS=wasip1 GOARCH=wasm go build -buildmode=c-shared -o validator.wasm ./testdata/wasm
+//
+// It implements the default policy with the policy package, so runs against it
+// should match runs without -wasm.
+package main
+
+import (
+	"unsafe"
+
+	"github.com/TotallyMonica/testRegex/policy"
+)
+
+var defaultPolicy = policy.Default()
+
+// buffers keeps allocations alive until dealloc
+var buffers = map[uintptr][]byte{}
+
+//go:wasmexport alloc
+func alloc(size int32) int32 {
+	buffer := make([]byte, max(size, 1))
+	ptr := uintptr(unsafe.Pointer(&buffer[0]))
+	buffers[ptr] = buffer
+	return int32(ptr)
+}
+
+//go:wasmexport dealloc
+func dealloc(ptr int32, size int32) {
+	delete(buffers, uintptr(ptr))
+}
+
+//go:wasmexport validate
+func validate(ptr int32, size int32) int32 {
+	password := string(buffers[uintptr(ptr)][:size])
+	if defaultPolicy.Accepts(password) {
+		return 1
+	}
+	return 0
+}
+
+func main() {}
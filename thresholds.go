@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// failureThreshold is the highest allowed share of failures, in percent, of the
+// passwords that could have failed that way. Negative rates fall back to the
+// overall thresholds.
+type failureThreshold struct {
+	falseAcceptRate float64
+	falseRejectRate float64
+}
+
+func orOverall(limit float64, overall float64) float64 {
+	if limit < 0 {
+		return overall
+	}
+	return limit
+}
+
+// categoryThresholds parses repeated -category-threshold flags like
+// should-pass:false-reject=0.1
+type categoryThresholds map[string]*failureThreshold
+
+func (c categoryThresholds) String() string {
+	var thresholds []string
+	for category, threshold := range c {
+		thresholds = append(thresholds, fmt.Sprintf("%s:false-accept=%g,false-reject=%g", category, threshold.falseAcceptRate, threshold.falseRejectRate))
+	}
+	sort.Strings(thresholds)
+	return strings.Join(thresholds, " ")
+}
+
+func (c categoryThresholds) Set(value string) error {
+	category, limit, ok := strings.Cut(value, ":")
+	if !ok || category == "" {
+		return fmt.Errorf("expected CATEGORY:false-accept=RATE or CATEGORY:false-reject=RATE, got %q", value)
+	}
+	if c[category] == nil {
+		c[category] = &failureThreshold{falseAcceptRate: -1, falseRejectRate: -1}
+	}
+
+	for _, part := range strings.Split(limit, ",") {
+		kind, rate, ok := strings.Cut(part, "=")
+		if !ok {
+			return fmt.Errorf("expected false-accept=RATE or false-reject=RATE, got %q", part)
+		}
+		parsed, err := strconv.ParseFloat(rate, 64)
+		if err != nil || parsed < 0 {
+			return fmt.Errorf("invalid rate %q", rate)
+		}
+		switch kind {
+		case "false-accept":
+			c[category].falseAcceptRate = parsed
+		case "false-reject":
+			c[category].falseRejectRate = parsed
+		default:
+			return fmt.Errorf("unknown failure type %q, expected false-accept or false-reject", kind)
+		}
+	}
+	return nil
+}
+
+func rate(failures int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(failures) / float64(total) * 100
+}
+
+// checkThresholds compares the new failure rates of the whole run and of every
+// category to their thresholds, printing and returning whether any was exceeded
+func (e *evaluation) checkThresholds() bool {
+	newFailures := make(map[string]*categoryCounts)
+	var total categoryCounts
+	for _, result := range append(e.falseAccepts, e.falseRejects...) {
+		if newFailures[result.category] == nil {
+			newFailures[result.category] = &categoryCounts{}
+		}
+		newFailures[result.category].add(result.expectedResult, result.actualResult)
+		total.add(result.expectedResult, result.actualResult)
+	}
+
+	exceeded := false
+	check := func(name string, failureType string, failures int, outOf int, limit float64) {
+		if rate(failures, outOf) > limit {
+			fmt.Printf("Threshold exceeded: %s %s rate is %.4g%% (%d out of %d), allowed %g%%\n", name, failureType, rate(failures, outOf), failures, outOf, limit)
+			exceeded = true
+		}
+	}
+
+	totals := e.totals()
+	check("overall", "false accept", total.falseAccepts, totals.trueRejects+totals.falseAccepts, maxFalseAcceptRate)
+	check("overall", "false reject", total.falseRejects, totals.trueAccepts+totals.falseRejects, maxFalseRejectRate)
+
+	for _, category := range sortedCategories(e.counts) {
+		threshold := categoryLimits[category]
+		if threshold == nil {
+			continue
+		}
+		c := e.counts[category]
+		failures := newFailures[category]
+		if failures == nil {
+			failures = &categoryCounts{}
+		}
+		check(categoryName(category), "false accept", failures.falseAccepts, c.trueRejects+c.falseAccepts, orOverall(threshold.falseAcceptRate, maxFalseAcceptRate))
+		check(categoryName(category), "false reject", failures.falseRejects, c.trueAccepts+c.falseRejects, orOverall(threshold.falseRejectRate, maxFalseRejectRate))
+	}
+	return exceeded
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+const wasmPageSize = 64 * 1024
+
+// wasmValidator runs a validator compiled to WebAssembly. The module exports its
+// memory and
+//
+//	alloc(size i32) -> ptr i32          memory for the password
+//	validate(ptr i32, len i32) -> i32   non-zero accepts the UTF-8 password
+//	dealloc(ptr i32, size i32)          optional, frees what alloc returned
+//
+// WASI is available for modules built by toolchains that need it.
+type wasmValidator struct {
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	module   api.Module
+	alloc    api.Function
+	validate api.Function
+	dealloc  api.Function
+	timeout  time.Duration
+	err      error // First error, after which the module is closed
+}
+
+func loadWasmValidator(path string, maxMemoryMiB int, timeout time.Duration) (*wasmValidator, error) {
+	binary, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(maxMemoryMiB * 1024 * 1024 / wasmPageSize)).
+		WithCloseOnContextDone(true)
+	runtime := wazero.NewRuntimeWithConfig(ctx, config)
+	wasi_snapshot_preview1.MustInstantiate(ctx, runtime)
+
+	compiled, err := runtime.CompileModule(ctx, binary)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("compiling %s: %w", path, err)
+	}
+	// Reactor modules are initialized with _initialize instead of running _start
+	moduleConfig := wazero.NewModuleConfig().WithStartFunctions("_initialize").WithStderr(os.Stderr)
+	module, err := runtime.InstantiateModule(ctx, compiled, moduleConfig)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("instantiating %s: %w", path, err)
+	}
+
+	v := &wasmValidator{
+		runtime:  runtime,
+		module:   module,
+		alloc:    module.ExportedFunction("alloc"),
+		validate: module.ExportedFunction("validate"),
+		dealloc:  module.ExportedFunction("dealloc"),
+		timeout:  timeout,
+	}
+	if v.alloc == nil || v.validate == nil || module.Memory() == nil {
+		runtime.Close(ctx)
+		return nil, fmt.Errorf("%s must export memory, alloc(size) and validate(ptr, len)", path)
+	}
+	return v, nil
+}
+
+// Accepts runs a single password through the module, it's safe for concurrent use.
+// A trap or running past the timeout rejects the password and is kept in Err.
+func (v *wasmValidator) Accepts(password string) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.err != nil {
+		return false
+	}
+	accepted, err := v.call(password)
+	if err != nil {
+		v.err = err
+	}
+	return accepted
+}
+
+// Err returns the first error the module ran into
+func (v *wasmValidator) Err() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return v.err
+}
+
+func (v *wasmValidator) call(password string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), v.timeout)
+	defer cancel()
+
+	size := uint64(len(password))
+	results, err := v.alloc.Call(ctx, size)
+	if err != nil {
+		return false, v.callError("alloc", ctx, err)
+	}
+	ptr := results[0]
+	if !v.module.Memory().WriteString(uint32(ptr), password) {
+		return false, fmt.Errorf("alloc returned %d, which is outside of the module's memory", ptr)
+	}
+
+	results, err = v.validate.Call(ctx, ptr, size)
+	if err != nil {
+		return false, v.callError("validate", ctx, err)
+	}
+	if v.dealloc != nil {
+		if _, err := v.dealloc.Call(ctx, ptr, size); err != nil {
+			return false, v.callError("dealloc", ctx, err)
+		}
+	}
+	return uint32(results[0]) != 0, nil
+}
+
+func (v *wasmValidator) callError(function string, ctx context.Context, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("%s took longer than %s", function, v.timeout)
+	}
+	return fmt.Errorf("%s: %w", function, err)
+}
+
+func (v *wasmValidator) Close() {
+	v.runtime.Close(context.Background())
+}